@@ -0,0 +1,395 @@
+// NOTE: `yap backup` and `yap restore`, the whole vault as one .tar.gz
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	// manifestName is the last entry of every backup, listing each file's checksum.
+	manifestName = ".yappad-manifest.json"
+	// backupPrefix starts the names of all backups; vaultBackupPrefix adds which vault they are of.
+	backupPrefix = "yappad-"
+	backupLayout = "20060102-150405"
+)
+
+type manifestEntry struct {
+	Path     string      `json:"path"`
+	Size     int64       `json:"size"`
+	Mode     fs.FileMode `json:"mode"`
+	Modified time.Time   `json:"modified"`
+	SHA256   string      `json:"sha256"`
+}
+
+type manifest struct {
+	Created time.Time       `json:"created"`
+	Vault   string          `json:"vault"`
+	Files   []manifestEntry `json:"files"`
+}
+
+func defaultBackupDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "share", "yappad", "backups")
+}
+
+/*
+	NOTE:
+
+vaultFiles lists every regular file in the vault, the metadata index
+and hidden folders like .templates, .history and .trash included. Only
+.locks is left out, as its files mean nothing once the editors holding
+them are gone. skip is a file to leave out, for when the archive is
+written inside the vault. Sizes and checksums are filled in as each file
+is archived.
+*/
+func vaultFiles(skip string) ([]manifestEntry, error) {
+	var files []manifestEntry
+	err := filepath.WalkDir(vaultDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".locks" {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() || p == skip {
+			return nil
+		}
+		rel, _ := filepath.Rel(vaultDir, p)
+		files = append(files, manifestEntry{Path: filepath.ToSlash(rel)})
+		return nil
+	})
+	return files, err
+}
+
+// writeBackup archives the vault to out. It writes to a temporary file first so a failed backup never replaces a good one.
+func writeBackup(out string) (manifest, error) {
+	abs, _ := filepath.Abs(out)
+	m := manifest{Created: time.Now(), Vault: vaultDir}
+	files, err := vaultFiles(abs)
+	if err != nil {
+		return m, err
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+		return m, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(out), ".backup-*.tmp")
+	if err != nil {
+		return m, err
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(zw)
+	m.Files, err = writeArchive(tw, m, files)
+	if cerr := tw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return m, err
+	}
+	return m, os.Rename(tmp.Name(), out)
+}
+
+/*
+	NOTE:
+
+writeArchive copies each file into the archive, hashing exactly the bytes
+it writes, and adds the manifest once every file is in. A note saved
+mid-backup is archived as it was when it was opened, and its checksum
+always matches what's in the archive.
+*/
+func writeArchive(tw *tar.Writer, m manifest, files []manifestEntry) ([]manifestEntry, error) {
+	for i, e := range files {
+		e, err := archiveEntry(tw, e)
+		if err != nil {
+			return nil, err
+		}
+		files[i] = e
+	}
+	m.Files = files
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	hdr := &tar.Header{Name: manifestName, Mode: 0o644, Size: int64(len(data)), ModTime: m.Created}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	_, err = tw.Write(data)
+	return files, err
+}
+
+func archiveEntry(tw *tar.Writer, e manifestEntry) (manifestEntry, error) {
+	f, err := os.Open(filepath.Join(vaultDir, filepath.FromSlash(e.Path)))
+	if err != nil {
+		return e, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return e, err
+	}
+	e.Size, e.Mode, e.Modified = info.Size(), info.Mode().Perm(), info.ModTime()
+
+	hdr := &tar.Header{Name: e.Path, Mode: int64(e.Mode), Size: e.Size, ModTime: e.Modified}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return e, err
+	}
+	// The copy is capped at the size the file had when opened, so a note written to mid-backup can't overrun its entry.
+	h := sha256.New()
+	if _, err := io.CopyN(tw, io.TeeReader(f, h), e.Size); err != nil {
+		return e, fmt.Errorf("%s changed during the backup: %w", e.Path, err)
+	}
+	e.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return e, nil
+}
+
+func cmdBackup(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	outFlag := fs.String("out", "", "")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	out := *outFlag
+	if out == "" {
+		out = vaultBackupPrefix() + time.Now().Format(backupLayout) + ".tar.gz"
+	}
+	m, err := writeBackup(out)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backed up %d files to %s\n", len(m.Files), out)
+	return nil
+}
+
+/*
+	NOTE:
+
+vaultBackupPrefix starts the names of this vault's backups: the vault's
+folder name for people, and a hash of its full path so two vaults with the
+same folder name, sharing one backup_dir, never rotate each other's
+archives away.
+*/
+func vaultBackupPrefix() string {
+	abs, err := filepath.Abs(vaultDir)
+	if err != nil {
+		abs = vaultDir
+	}
+	sum := sha256.Sum256([]byte(abs))
+	name := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, filepath.Base(abs))
+	return backupPrefix + strings.TrimLeft(name, ".") + "-" + hex.EncodeToString(sum[:4]) + "-"
+}
+
+// autoBackup writes a backup into dir and deletes all but the newest keep automatic backups of this vault there.
+func autoBackup(dir string, keep int) error {
+	prefix := vaultBackupPrefix()
+	name := prefix + time.Now().Format(backupLayout) + ".tar.gz"
+	if _, err := writeBackup(filepath.Join(dir, name)); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var backups []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), prefix) && strings.HasSuffix(e.Name(), ".tar.gz") {
+			backups = append(backups, e.Name())
+		}
+	}
+	// The timestamp in the name sorts oldest first.
+	sort.Strings(backups)
+	for len(backups) > keep {
+		if err := os.Remove(filepath.Join(dir, backups[0])); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// archiveFile is a file read back out of a backup.
+type archiveFile struct {
+	entry manifestEntry
+	data  []byte
+}
+
+/*
+	NOTE:
+
+readBackup reads a whole archive and checks it against its manifest
+before anything is restored: every file must be listed with a matching
+checksum, every listed file must be there, and no path may leave the
+directory being restored into. The manifest is the last entry; backups
+made before that change have it first, and are read the same way.
+*/
+func readBackup(archive string) (manifest, []archiveFile, error) {
+	var m manifest
+	f, err := os.Open(archive)
+	if err != nil {
+		return m, nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return m, nil, fmt.Errorf("%s is not a YapPad backup: %w", archive, err)
+	}
+	tr := tar.NewReader(zr)
+
+	type entry struct {
+		name string
+		data []byte
+	}
+	var entries []entry
+	haveManifest := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return m, nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if hdr.Name == manifestName {
+			if haveManifest {
+				return m, nil, fmt.Errorf("%s has two manifests", archive)
+			}
+			if err := json.NewDecoder(tr).Decode(&m); err != nil {
+				return m, nil, fmt.Errorf("reading manifest: %w", err)
+			}
+			haveManifest = true
+			continue
+		}
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return m, nil, fmt.Errorf("archive entry %q points outside the vault", hdr.Name)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return m, nil, err
+		}
+		entries = append(entries, entry{name, data})
+	}
+	if !haveManifest {
+		return m, nil, fmt.Errorf("%s is not a YapPad backup: no manifest", archive)
+	}
+
+	listed := make(map[string]manifestEntry, len(m.Files))
+	for _, e := range m.Files {
+		listed[e.Path] = e
+	}
+	var files []archiveFile
+	for _, en := range entries {
+		e, ok := listed[en.name]
+		if !ok {
+			return m, nil, fmt.Errorf("archive entry %q is not in the manifest", en.name)
+		}
+		sum := sha256.Sum256(en.data)
+		if hex.EncodeToString(sum[:]) != e.SHA256 {
+			return m, nil, fmt.Errorf("%s: checksum mismatch, the archive is damaged", en.name)
+		}
+		files = append(files, archiveFile{entry: e, data: en.data})
+		delete(listed, en.name)
+	}
+	for name := range listed {
+		return m, nil, fmt.Errorf("%s is in the manifest but missing from the archive", name)
+	}
+	return m, files, nil
+}
+
+func cmdRestore(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	intoFlag := fs.String("into", "", "")
+	dryRun := fs.Bool("dry-run", false, "")
+	force := fs.Bool("force", false, "")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: yap restore <archive> [--into dir] [--dry-run] [--force]")
+	}
+	into := *intoFlag
+	if into == "" {
+		into = vaultDir
+	}
+
+	m, files, err := readBackup(positional[0])
+	if err != nil {
+		return err
+	}
+
+	var added, same, conflicts []archiveFile
+	for _, f := range files {
+		existing, err := os.ReadFile(filepath.Join(into, filepath.FromSlash(f.entry.Path)))
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			added = append(added, f)
+		case err != nil:
+			return err
+		case bytes.Equal(existing, f.data):
+			same = append(same, f)
+		default:
+			conflicts = append(conflicts, f)
+		}
+	}
+
+	fmt.Printf("Backup of %s from %s: %d files\n", m.Vault, m.Created.Format(time.RFC822), len(files))
+	fmt.Printf("  %d new, %d unchanged, %d conflicting\n", len(added), len(same), len(conflicts))
+	for _, f := range conflicts {
+		fmt.Printf("  conflict: %s\n", f.entry.Path)
+	}
+	if *dryRun {
+		return nil
+	}
+	if len(conflicts) > 0 && !*force {
+		return fmt.Errorf("%d files in %s differ from the backup; use --force to overwrite them", len(conflicts), into)
+	}
+
+	for _, f := range append(added, conflicts...) {
+		dst := filepath.Join(into, filepath.FromSlash(f.entry.Path))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(dst, f.data, f.entry.Mode.Perm()); err != nil {
+			return err
+		}
+		os.Chtimes(dst, f.entry.Modified, f.entry.Modified)
+	}
+	fmt.Printf("Restored %d files into %s\n", len(added)+len(conflicts), into)
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type tarEntry struct {
+	name, data string
+}
+
+// writeTarGz writes entries, in order, to a .tar.gz in a temp dir and returns its path.
+func writeTarGz(t *testing.T, entries []tarEntry) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "backup.tar.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	tw := tar.NewWriter(zw)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0o644, Size: int64(len(e.data)), ModTime: time.Now()}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// manifestFor lists files with their checksums, the way writeArchive does.
+func manifestFor(t *testing.T, files []tarEntry) string {
+	t.Helper()
+	m := manifest{Created: time.Now(), Vault: "/vault"}
+	for _, f := range files {
+		sum := sha256.Sum256([]byte(f.data))
+		m.Files = append(m.Files, manifestEntry{
+			Path:   f.name,
+			Size:   int64(len(f.data)),
+			Mode:   0o644,
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestReadBackup(t *testing.T) {
+	notes := []tarEntry{{"a.md", "alpha"}, {"dir/b.md", "beta"}}
+	good := manifestFor(t, notes)
+
+	tests := []struct {
+		name    string
+		entries []tarEntry
+		wantErr string
+	}{
+		{
+			name:    "manifest last",
+			entries: append(append([]tarEntry{}, notes...), tarEntry{manifestName, good}),
+		},
+		{
+			name:    "manifest first",
+			entries: append([]tarEntry{{manifestName, good}}, notes...),
+		},
+		{
+			name:    "tampered",
+			entries: []tarEntry{{"a.md", "ALPHA"}, {"dir/b.md", "beta"}, {manifestName, good}},
+			wantErr: "checksum mismatch",
+		},
+		{
+			name:    "extra entry",
+			entries: []tarEntry{{"a.md", "alpha"}, {"dir/b.md", "beta"}, {"c.md", "gamma"}, {manifestName, good}},
+			wantErr: "not in the manifest",
+		},
+		{
+			name: "escaping path",
+			entries: []tarEntry{
+				{"../evil.md", "x"},
+				{manifestName, manifestFor(t, []tarEntry{{"../evil.md", "x"}})},
+			},
+			wantErr: "outside the vault",
+		},
+		{
+			name: "absolute path",
+			entries: []tarEntry{
+				{"/etc/evil", "x"},
+				{manifestName, manifestFor(t, []tarEntry{{"/etc/evil", "x"}})},
+			},
+			wantErr: "outside the vault",
+		},
+		{
+			name:    "missing entry",
+			entries: []tarEntry{{"a.md", "alpha"}, {manifestName, good}},
+			wantErr: "missing from the archive",
+		},
+		{
+			name:    "no manifest",
+			entries: notes,
+			wantErr: "no manifest",
+		},
+		{
+			name:    "two manifests",
+			entries: append(append([]tarEntry{{manifestName, good}}, notes...), tarEntry{manifestName, good}),
+			wantErr: "two manifests",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, files, err := readBackup(writeTarGz(t, tt.entries))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(m.Files) != len(notes) || len(files) != len(notes) {
+				t.Fatalf("got %d manifest entries and %d files, want %d", len(m.Files), len(files), len(notes))
+			}
+		})
+	}
+}
+
+func TestReadBackupNotGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.tar.gz")
+	if err := os.WriteFile(path, []byte("just text"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := readBackup(path); err == nil || !strings.Contains(err.Error(), "not a YapPad backup") {
+		t.Fatalf("err = %v, want a not-a-backup error", err)
+	}
+}
+
+func TestBackupRestoreRoundTrip(t *testing.T) {
+	files := map[string]string{
+		"a.md":              "alpha",
+		"dir/b.md":          "beta",
+		".history/a/1.gz":   "old",
+		".trash/1/c.md":     "trashed",
+		metaIndexName:       `{"version": 1, "notes": {"a.md": {"description": "about a"}}}`,
+		".locks/a.md.lock":  "",
+		"empty.md":          "",
+		"ünïcode/ⱥ note.md": "ⱥ",
+	}
+	writeVault(t, files)
+	vault := vaultDir
+
+	archive := filepath.Join(t.TempDir(), "b.tar.gz")
+	m, err := writeBackup(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != len(files)-1 {
+		t.Errorf("backed up %d files, want %d (all but the lock)", len(m.Files), len(files)-1)
+	}
+
+	into := filepath.Join(t.TempDir(), "restored")
+	if err := cmdRestore(Config{}, []string{archive, "--into", into}); err != nil {
+		t.Fatal(err)
+	}
+	for rel, want := range files {
+		got, err := os.ReadFile(filepath.Join(into, filepath.FromSlash(rel)))
+		if strings.HasPrefix(rel, ".locks/") {
+			if err == nil {
+				t.Errorf("%s was restored, locks should be left out", rel)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", rel, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", rel, got, want)
+		}
+	}
+
+	// Restoring over a vault that changed since is refused unless forced.
+	if err := os.WriteFile(filepath.Join(vault, "a.md"), []byte("edited"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmdRestore(Config{}, []string{archive}); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("err = %v, want a conflict error", err)
+	}
+	if err := cmdRestore(Config{}, []string{archive, "--force"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(vault, "a.md")); string(got) != "alpha" {
+		t.Errorf("after --force a.md = %q, want %q", got, "alpha")
+	}
+}
@@ -0,0 +1,135 @@
+// NOTE: Quick capture. `yap add` appends text to the inbox note without opening the TUI.
+
+package main
+
+import (
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const defaultInbox = "inbox.md"
+
+// captureText reads the text to capture from the arguments, or from stdin when it is piped.
+func captureText(args []string) (string, error) {
+	if len(args) > 0 {
+		return strings.Join(args, " "), nil
+	}
+	info, err := os.Stdin.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice != 0 {
+		return "", errors.New(`usage: yap add "text"  or  echo text | yap add`)
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// appendCapture appends text under a timestamp header to the note at path.
+func appendCapture(path, text string, now time.Time) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var b strings.Builder
+	if len(existing) > 0 {
+		if !strings.HasSuffix(string(existing), "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("## " + now.Format("2006-01-02 15:04") + "\n\n")
+	b.WriteString(strings.TrimRight(text, "\n") + "\n")
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+/*
+	NOTE:
+
+cmdAdd implements `yap add [--new] [--to note] [--wait dur] [text]`.
+The note is locked while writing. If the TUI has it open in an editor,
+add waits up to --wait for it to be closed rather than writing under it.
+*/
+func cmdAdd(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	newFlag := fs.Bool("new", false, "")
+	toFlag := fs.String("to", "", "")
+	waitFlag := fs.Duration("wait", 5*time.Second, "")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	text, err := captureText(rest)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(text) == "" {
+		return errors.New("nothing to add")
+	}
+
+	now := time.Now()
+	if *newFlag {
+		name := now.Format("2006-01-02-150405") + ".md"
+		path, err := notePath(name)
+		if err != nil {
+			return err
+		}
+		content := "# " + now.Format("2006-01-02 15:04") + "\n\n" + strings.TrimRight(text, "\n") + "\n"
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		if _, err := f.WriteString(content); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Println(name)
+		return nil
+	}
+
+	name := cmp.Or(*toFlag, cfg.Inbox, defaultInbox)
+	if filepath.Ext(name) == "" {
+		name += ".md"
+	}
+	path, err := notePath(name)
+	if err != nil {
+		return err
+	}
+	if isEncryptedFile(path) {
+		return fmt.Errorf("%s is encrypted, it can only be edited in YapPad", name)
+	}
+
+	lock, err := lockNote(path, *waitFlag)
+	if errors.Is(err, errNoteLocked) {
+		return fmt.Errorf("%s is open in YapPad's editor, close it or retry with a longer --wait", name)
+	}
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	return appendCapture(path, text, now)
+}
@@ -0,0 +1,272 @@
+/*
+NOTE:
+Headless subcommands (yap ls, new, cat, rm, mv, search, ...).
+They work on the same vault as the TUI without starting Bubble Tea.
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// errNoMatches makes `yap search` exit with status 1 and no message, like grep.
+var errNoMatches = errors.New("no matches")
+
+var commands = map[string]func(cfg Config, args []string) error{
+	"today":   cmdToday,
+	"ls":      cmdLs,
+	"new":     cmdNew,
+	"cat":     cmdCat,
+	"rm":      cmdRm,
+	"mv":      cmdMv,
+	"search":  cmdSearch,
+	"add":     cmdAdd,
+	"export":  cmdExport,
+	"backup":  cmdBackup,
+	"restore": cmdRestore,
+	"import":  cmdImport,
+}
+
+// parseArgs parses flags that may appear before, between or after positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+/*
+	NOTE:
+
+resolveNote turns a note name from the command line into a path inside the
+vault. The .md extension is optional, like in the TUI, and paths that would
+escape the vault are rejected. So are folders: moving or trashing one here
+would leave the descriptions, pins and history of the notes inside behind.
+*/
+func resolveNote(name string) (string, error) {
+	path, err := notePath(name)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(path); err == nil {
+		if info.IsDir() {
+			return "", fmt.Errorf("%s is a folder, not a note", name)
+		}
+		return path, nil
+	}
+	if filepath.Ext(path) == "" {
+		if _, err := os.Stat(path + ".md"); err == nil {
+			return path + ".md", nil
+		}
+	}
+	return "", fmt.Errorf("no such note: %s", name)
+}
+
+// notePath joins name onto the vault, making sure the result stays inside it.
+func notePath(name string) (string, error) {
+	path := filepath.Join(vaultDir, name)
+	rel, err := filepath.Rel(vaultDir, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the vault", name)
+	}
+	return path, nil
+}
+
+type noteJSON struct {
+	Path        string    `json:"path"`
+	Description string    `json:"description,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Modified    time.Time `json:"modified"`
+	Created     time.Time `json:"created"`
+}
+
+func cmdLs(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("ls", flag.ContinueOnError)
+	sortFlag := fs.String("sort", "modified", "")
+	jsonFlag := fs.Bool("json", false, "")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	sMode, err := parseSortMode(*sortFlag)
+	if err != nil {
+		return err
+	}
+
+	items := listFiles(sMode)
+	if !*jsonFlag {
+		for _, it := range items {
+			fmt.Println(it.(item).title)
+		}
+		return nil
+	}
+
+	notes := make([]noteJSON, 0, len(items))
+	for _, it := range items {
+		i := it.(item)
+		notes = append(notes, noteJSON{
+			Path:        i.title,
+			Description: readMetaDesc(filepath.Join(vaultDir, i.title)),
+			Tags:        i.tags,
+			Modified:    i.modTime,
+			Created:     i.creTime,
+		})
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(notes)
+}
+
+func cmdNew(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("new", flag.ContinueOnError)
+	descFlag := fs.String("desc", "", "")
+	templateFlag := fs.String("template", "", "")
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(names) != 1 {
+		return errors.New("usage: yap new <name> [--desc text] [--template name]")
+	}
+
+	name := names[0]
+	if filepath.Ext(name) == "" {
+		name += ".md"
+	}
+	path, err := notePath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", name)
+	}
+
+	var content string
+	if *templateFlag != "" {
+		if isEncryptedFile(path) {
+			return errors.New("templates can't be applied to encrypted notes outside YapPad")
+		}
+		tmpl, err := readTemplate(*templateFlag)
+		if err != nil {
+			return err
+		}
+		content, _, _ = applyTemplate(tmpl, path, *descFlag, time.Now())
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return err
+	}
+	if err := writeMetaDesc(path, *descFlag); err != nil {
+		return err
+	}
+	fmt.Println(name)
+	return nil
+}
+
+func cmdCat(cfg Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: yap cat <note>...")
+	}
+	for _, name := range args {
+		path, err := resolveNote(name)
+		if err != nil {
+			return err
+		}
+		if isEncryptedFile(path) {
+			return fmt.Errorf("%s is encrypted, open it in YapPad to read it", name)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		os.Stdout.Write(data)
+	}
+	return nil
+}
+
+func cmdRm(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("rm", flag.ContinueOnError)
+	purgeFlag := fs.Bool("purge", false, "")
+	names, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return errors.New("usage: yap rm [--purge] <note>...")
+	}
+
+	for _, name := range names {
+		path, err := resolveNote(name)
+		if err != nil {
+			return err
+		}
+		if *purgeFlag {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			deleteMeta(path)
+			os.RemoveAll(historyDir(path))
+			continue
+		}
+		if _, err := moveToTrash(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cmdMv(cfg Config, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: yap mv <note> <new name>")
+	}
+	oldPath, err := resolveNote(args[0])
+	if err != nil {
+		return err
+	}
+
+	name := args[1]
+	if strings.HasSuffix(name, "/") {
+		name = filepath.Join(name, filepath.Base(oldPath))
+	} else if filepath.Ext(name) == "" {
+		name += filepath.Ext(oldPath)
+	}
+	newPath, err := notePath(name)
+	if err != nil {
+		return err
+	}
+	return renameNote(oldPath, newPath)
+}
+
+func cmdSearch(cfg Config, args []string) error {
+	query := strings.Join(args, " ")
+	if query == "" {
+		return errors.New("usage: yap search <query>")
+	}
+	matches := searchVault(query, sortNameAsc)
+	for _, match := range matches {
+		fmt.Printf("%s:%d: %s\n", match.title, match.line, strings.TrimSpace(match.text))
+	}
+	if len(matches) == 0 {
+		return errNoMatches
+	}
+	return nil
+}
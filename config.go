@@ -13,11 +13,30 @@ import (
 )
 
 type Config struct {
-	Theme  string `toml:"theme"`
-	Editor string `toml:"editor"`
-	Vault  string `toml:"vault"`
+	Theme     string `toml:"theme"`
+	Editor    string `toml:"editor"`
+	Vault     string `toml:"vault"`
+	TrashDays int    `toml:"trash_days"` // purge trashed notes older than this, 0 keeps them forever
+	TreeView  bool   `toml:"tree_view"`  // start with folders shown as a collapsible tree
+
+	DailyPath     string `toml:"daily_path"`     // Go time layout, relative to the vault
+	DailyTemplate string `toml:"daily_template"` // template in .templates for new daily notes
+
+	Inbox string `toml:"inbox"` // note that `yap add` appends to
+
+	Autosave      int  `toml:"autosave"`       // seconds between inbuilt editor autosaves, 0 turns it off
+	EditorPreview bool `toml:"editor_preview"` // open markdown notes with the live preview beside the editor
+	VimMode       bool `toml:"vim_mode"`       // vim-style modal editing in the inbuilt editor
+
+	Themes map[string]ThemeConfig `toml:"themes"` // user-defined themes, by name
+	Keys   map[string]keyList     `toml:"keys"`   // key binding overrides, by action
+
+	BackupKeep int    `toml:"backup_keep"` // automatic backups kept when the TUI exits, 0 turns them off
+	BackupDir  string `toml:"backup_dir"`  // where automatic backups go
 }
 
+const defaultTrashDays = 30
+
 func configPath() string {
 	home, _ := os.UserHomeDir()
 	return filepath.Join(home, ".config", "yappad", "config.toml")
@@ -25,9 +44,12 @@ func configPath() string {
 
 func loadConfig() Config {
 	cfg := Config{
-		Theme:  "default",
-		Editor: "",
-		Vault:  filepath.Join(os.Getenv("HOME"), ".YapPad"),
+		Theme:     "default",
+		Editor:    "",
+		Vault:     filepath.Join(os.Getenv("HOME"), ".YapPad"),
+		TrashDays: defaultTrashDays,
+		DailyPath: defaultDailyPath,
+		Inbox:     defaultInbox,
 	}
 
 	path := configPath()
@@ -39,17 +61,28 @@ func loadConfig() Config {
 		fmt.Fprintf(os.Stderr, "warning: could not parse config: %v\n", err)
 	}
 
-	if cfg.Vault != "" {
-		cfg.Vault = os.ExpandEnv(cfg.Vault)
-		if strings.HasPrefix(cfg.Vault, "~") {
-			home, _ := os.UserHomeDir()
-			cfg.Vault = filepath.Join(home, cfg.Vault[1:])
-		}
+	cfg.Vault = expandPath(cfg.Vault)
+	cfg.BackupDir = expandPath(cfg.BackupDir)
+	if cfg.BackupDir == "" {
+		cfg.BackupDir = defaultBackupDir()
 	}
 
 	return cfg
 }
 
+// expandPath expands environment variables and a leading ~ in a path from the config.
+func expandPath(p string) string {
+	if p == "" {
+		return p
+	}
+	p = os.ExpandEnv(p)
+	if strings.HasPrefix(p, "~") {
+		home, _ := os.UserHomeDir()
+		p = filepath.Join(home, p[1:])
+	}
+	return p
+}
+
 func writeConfig(cfg Config) error {
 	path := configPath()
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
@@ -65,12 +98,12 @@ func writeConfig(cfg Config) error {
 
 func runSetup() Config {
 	reader := bufio.NewReader(os.Stdin)
-	cfg := Config{}
+	cfg := Config{TrashDays: defaultTrashDays, DailyPath: defaultDailyPath, Inbox: defaultInbox}
 
 	home, _ := os.UserHomeDir()
 	defaultVault := filepath.Join(home, ".YapPad")
 
-	fmt.Println("Welcome to YapPad! Let's set things up.\n")
+	fmt.Print("Welcome to YapPad! Let's set things up.\n\n")
 
 	// Vault
 	fmt.Printf("Where do you want to store your notes? (Default directory [%s]) : ", defaultVault)
@@ -0,0 +1,334 @@
+// NOTE: Encrypted notes (*.enc). AES-256-GCM with a key derived from a per-session passphrase.
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const encryptedExt = ".enc"
+
+/*
+	NOTE:
+
+File layout:
+
+	"YAPENC1\n" | salt (16 bytes) | nonce (12 bytes) | AES-GCM ciphertext
+
+The key is PBKDF2-SHA256 over the passphrase and the file's salt. Saving
+an existing note keeps its salt, so the key is derived once per note per
+session; the nonce is fresh on every write.
+*/
+var encryptedMagic = []byte("YAPENC1\n")
+
+const (
+	saltSize         = 16
+	pbkdf2Iterations = 600_000
+)
+
+var (
+	errNoPassphrase    = errors.New("passphrase required")
+	errWrongPassphrase = errors.New("wrong passphrase or corrupted note")
+)
+
+// The passphrase lives only in memory for the life of the process.
+var (
+	sessionPassphrase string
+	derivedKeys       = map[string][]byte{}
+	passphraseMu      sync.Mutex
+)
+
+func isEncryptedFile(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), encryptedExt)
+}
+
+// plainName strips the encryption suffix, so secret.md.enc is rendered like secret.md.
+func plainName(path string) string {
+	if isEncryptedFile(path) {
+		return path[:len(path)-len(encryptedExt)]
+	}
+	return path
+}
+
+func havePassphrase() bool {
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	return sessionPassphrase != ""
+}
+
+func setPassphrase(p string) {
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	sessionPassphrase = p
+	derivedKeys = map[string][]byte{}
+}
+
+func clearPassphrase() {
+	setPassphrase("")
+}
+
+func deriveKey(salt []byte) ([]byte, error) {
+	passphraseMu.Lock()
+	defer passphraseMu.Unlock()
+	if sessionPassphrase == "" {
+		return nil, errNoPassphrase
+	}
+	if key, ok := derivedKeys[string(salt)]; ok {
+		return key, nil
+	}
+	key, err := pbkdf2.Key(sha256.New, sessionPassphrase, salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	derivedKeys[string(salt)] = key
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptNote opens an encrypted container. An empty file decrypts to an empty note.
+func decryptNote(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if !bytes.HasPrefix(data, encryptedMagic) || len(data) < len(encryptedMagic)+saltSize+12 {
+		return nil, errWrongPassphrase
+	}
+	data = data[len(encryptedMagic):]
+	salt, data := data[:saltSize], data[saltSize:]
+
+	key, err := deriveKey(salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, encryptedMagic)
+	if err != nil {
+		return nil, errWrongPassphrase
+	}
+	return plain, nil
+}
+
+func encryptNote(plain, salt []byte) ([]byte, error) {
+	key, err := deriveKey(salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append([]byte{}, encryptedMagic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plain, encryptedMagic), nil
+}
+
+// readNote reads a note, decrypting it in memory if it is encrypted.
+func readNote(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !isEncryptedFile(path) {
+		return data, err
+	}
+	return decryptNote(data)
+}
+
+// writeEncryptedNote encrypts plain and writes it to path, reusing the note's salt when it has one.
+func writeEncryptedNote(path string, plain []byte) error {
+	salt := make([]byte, saltSize)
+	if existing, err := os.ReadFile(path); err == nil &&
+		bytes.HasPrefix(existing, encryptedMagic) && len(existing) >= len(encryptedMagic)+saltSize {
+		copy(salt, existing[len(encryptedMagic):])
+	} else if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+
+	data, err := encryptNote(plain, salt)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// encryptFile replaces the plaintext note at path with path.enc, returning the new path.
+// Its history is dropped, since the snapshots hold plaintext.
+func encryptFile(path string) (string, error) {
+	newPath := path + encryptedExt
+	if _, err := os.Stat(newPath); err == nil {
+		return "", errors.New(newPath[len(vaultDir)+1:] + " already exists")
+	}
+	plain, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if err := writeEncryptedNote(newPath, plain); err != nil {
+		os.Remove(newPath)
+		return "", err
+	}
+
+	if err := os.Remove(path); err != nil {
+		return "", err
+	}
+	os.RemoveAll(historyDir(path))
+	moveMeta(path, newPath)
+	return newPath, nil
+}
+
+// decryptFile turns the encrypted note at path back into a plaintext note, returning the new path.
+func decryptFile(path string) (string, error) {
+	newPath := plainName(path)
+	if _, err := os.Stat(newPath); err == nil {
+		return "", errors.New(newPath[len(vaultDir)+1:] + " already exists")
+	}
+	plain, err := readNote(path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(newPath, plain, 0o644); err != nil {
+		return "", err
+	}
+
+	if err := os.Remove(path); err != nil {
+		return "", err
+	}
+	moveMeta(path, newPath)
+	return newPath, nil
+}
+
+// passAction is what to do once the passphrase prompt is answered.
+type passAction int
+
+const (
+	passPreview passAction = iota
+	passEdit
+	passToggle
+)
+
+// promptPassphrase asks for the session passphrase before running action on path.
+func (m model) promptPassphrase(action passAction, path string) (tea.Model, tea.Cmd) {
+	m.passMode = true
+	m.passAction = action
+	m.passPath = path
+	m.passFirst = ""
+	m.passInput.SetValue("")
+	m.passInput.Focus()
+	return m, textinput.Blink
+}
+
+// passPromptLabel is the text shown before the passphrase input.
+func (m model) passPromptLabel() string {
+	switch {
+	case m.passFirst != "":
+		return "Confirm passphrase"
+	case m.passAction == passToggle && !isEncryptedFile(m.passPath):
+		return "New passphrase"
+	}
+	return "Passphrase for " + filepath.Base(m.passPath)
+}
+
+/*
+	NOTE:
+
+submitPassphrase checks the entered passphrase. Unlocking tries it against
+the note straight away; choosing one to encrypt with asks for it twice,
+since a typo there would lock the note for good.
+*/
+func (m model) submitPassphrase() (tea.Model, tea.Cmd) {
+	p := m.passInput.Value()
+	if p == "" {
+		return m, nil
+	}
+	m.passInput.SetValue("")
+
+	if m.passAction == passToggle && !isEncryptedFile(m.passPath) {
+		if m.passFirst == "" {
+			m.passFirst = p
+			return m, nil
+		}
+		if p != m.passFirst {
+			m.passFirst = ""
+			return m, m.list.NewStatusMessage("Passphrases don't match")
+		}
+		setPassphrase(p)
+	} else {
+		setPassphrase(p)
+		if _, err := readNote(m.passPath); err != nil {
+			clearPassphrase()
+			return m, m.list.NewStatusMessage("Wrong passphrase")
+		}
+	}
+
+	m.passMode = false
+	m.passFirst = ""
+	m.passInput.Blur()
+	switch m.passAction {
+	case passEdit:
+		return m.editNote(m.passPath, -1, -1)
+	case passToggle:
+		return m.toggleEncryption(m.passPath)
+	}
+	if m.selectedFile == "" || !m.showPreview {
+		return m, nil
+	}
+	m.loadingFile = true
+	return m, tea.Batch(m.spinner.Tick, m.loadFileOrImage(m.resolveFilePath(m.selectedFile)))
+}
+
+// toggleEncryption encrypts a plaintext note, or turns an encrypted one back into plaintext.
+func (m model) toggleEncryption(path string) (tea.Model, tea.Cmd) {
+	if !havePassphrase() {
+		return m.promptPassphrase(passToggle, path)
+	}
+
+	var newPath, verb string
+	var err error
+	if isEncryptedFile(path) {
+		newPath, err = decryptFile(path)
+		verb = "Decrypted "
+	} else {
+		newPath, err = encryptFile(path)
+		verb = "Encrypted "
+	}
+	if errors.Is(err, errWrongPassphrase) {
+		clearPassphrase()
+		newM, cmd := m.promptPassphrase(passToggle, path)
+		return newM, tea.Batch(cmd, m.list.NewStatusMessage("Wrong passphrase"))
+	}
+	if err != nil {
+		return m, m.list.NewStatusMessage("Encryption failed: " + err.Error())
+	}
+
+	rel, _ := filepath.Rel(vaultDir, newPath)
+	m.list.SetItems(m.listItems())
+	newM, cmd := m.jumpToNote(rel)
+	m = newM.(model)
+	return m, tea.Batch(cmd, m.list.NewStatusMessage(verb+rel))
+}
@@ -0,0 +1,165 @@
+// NOTE: Daily notes. One note per day at a path built from a Go time layout.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const defaultDailyPath = "journal/2006/01/2006-01-02.md"
+
+// dailyNotePath returns the absolute path of the daily note for day t.
+func dailyNotePath(t time.Time, pattern string) string {
+	if pattern == "" {
+		pattern = defaultDailyPath
+	}
+	return filepath.Join(vaultDir, filepath.FromSlash(t.Format(pattern)))
+}
+
+/*
+	NOTE:
+
+ensureDailyNote creates the daily note for day t if it doesn't exist yet,
+filling it from the daily template when one is configured. It returns the
+note's path and where {{cursor}} was, or (-1, -1).
+*/
+func ensureDailyNote(t time.Time, pattern, templateName string) (string, int, int, error) {
+	path := dailyNotePath(t, pattern)
+	if _, err := os.Stat(path); err == nil {
+		return path, -1, -1, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", -1, -1, err
+	}
+
+	content, row, col := "", -1, -1
+	if templateName != "" {
+		tmpl, err := readTemplate(templateName)
+		if err != nil {
+			return "", -1, -1, fmt.Errorf("daily template: %w", err)
+		}
+		content, row, col = applyTemplate(tmpl, path, "", t)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return "", -1, -1, err
+	}
+	return path, row, col, nil
+}
+
+// dailyNoteDate reports the day a vault-relative title is the daily note for, if any.
+func dailyNoteDate(title, pattern string) (time.Time, bool) {
+	if pattern == "" {
+		pattern = defaultDailyPath
+	}
+	t, err := time.ParseInLocation(pattern, filepath.ToSlash(title), time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// adjacentDailyNote finds the nearest existing daily note before (dir < 0) or after (dir > 0) day.
+func adjacentDailyNote(day time.Time, dir int, pattern string) (string, bool) {
+	type daily struct {
+		title string
+		day   time.Time
+	}
+	var notes []daily
+	for _, it := range listFiles(sortNameAsc) {
+		title := it.(item).title
+		if t, ok := dailyNoteDate(title, pattern); ok {
+			notes = append(notes, daily{title, t})
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].day.Before(notes[j].day) })
+
+	day = startOfDay(day)
+	if dir < 0 {
+		for i := len(notes) - 1; i >= 0; i-- {
+			if notes[i].day.Before(day) {
+				return notes[i].title, true
+			}
+		}
+		return "", false
+	}
+	for _, n := range notes {
+		if n.day.After(day) {
+			return n.title, true
+		}
+	}
+	return "", false
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// parseDayArg understands "today", "yesterday", "tomorrow", +N / -N day offsets and YYYY-MM-DD.
+func parseDayArg(arg string, now time.Time) (time.Time, error) {
+	switch arg {
+	case "", "today":
+		return now, nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	case "tomorrow":
+		return now.AddDate(0, 0, 1), nil
+	}
+	if strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-") {
+		if n, err := strconv.Atoi(arg); err == nil {
+			return now.AddDate(0, 0, n), nil
+		}
+	}
+	t, err := time.ParseInLocation("2006-01-02", arg, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid day %q (want today, yesterday, tomorrow, +N, -N or YYYY-MM-DD)", arg)
+	}
+	return t, nil
+}
+
+// openDailyNote creates the daily note for day if needed and opens it in the configured editor.
+func (m model) openDailyNote(day time.Time) (tea.Model, tea.Cmd) {
+	path, row, col, err := ensureDailyNote(day, m.dailyPath, m.dailyTemplate)
+	if err != nil {
+		return m, m.list.NewStatusMessage("Daily note: " + err.Error())
+	}
+
+	rel, _ := filepath.Rel(vaultDir, path)
+	m.list.SetItems(m.listItems())
+	newM, _ := m.jumpToNote(rel)
+	m = newM.(model)
+
+	return m.editNote(path, row, col)
+}
+
+// cmdToday implements `yap today [day]`.
+func cmdToday(cfg Config, args []string) error {
+	arg := ""
+	if len(args) > 0 {
+		arg = args[0]
+	}
+	day, err := parseDayArg(arg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if cfg.Editor == "inbuilt" {
+		newM, _ := initialModel(cfg).openDailyNote(day)
+		return runProgram(newM.(model))
+	}
+
+	path, row, _, err := ensureDailyNote(day, cfg.DailyPath, cfg.DailyTemplate)
+	if err != nil {
+		return err
+	}
+	return runEditor(path, cfg.Editor, row+1)
+}
@@ -0,0 +1,183 @@
+// NOTE: Line-based unified diff, used by the history view
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+type diffLine struct {
+	op   diffOp
+	text string
+}
+
+// maxDiffCells caps the LCS table so huge files can't eat all memory.
+const maxDiffCells = 4_000_000
+
+// diffLines returns the edit script turning a into b.
+func diffLines(a, b []string) []diffLine {
+	// Common prefix and suffix are trimmed first, which keeps typical edits tiny.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var out []diffLine
+	for _, l := range a[:prefix] {
+		out = append(out, diffLine{diffEqual, l})
+	}
+	out = append(out, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		out = append(out, diffLine{diffEqual, l})
+	}
+	return out
+}
+
+func diffMiddle(a, b []string) []diffLine {
+	var out []diffLine
+	if len(a)*len(b) > maxDiffCells {
+		for _, l := range a {
+			out = append(out, diffLine{diffDelete, l})
+		}
+		for _, l := range b {
+			out = append(out, diffLine{diffInsert, l})
+		}
+		return out
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:].
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, diffLine{diffEqual, a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, diffLine{diffDelete, a[i]})
+			i++
+		default:
+			out = append(out, diffLine{diffInsert, b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, diffLine{diffDelete, a[i]})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, diffLine{diffInsert, b[j]})
+	}
+	return out
+}
+
+// diffStats counts inserted and deleted lines.
+func diffStats(lines []diffLine) (added, removed int) {
+	for _, l := range lines {
+		switch l.op {
+		case diffInsert:
+			added++
+		case diffDelete:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+/*
+	NOTE:
+
+unifiedDiff groups the edit script into hunks with `context` unchanged
+lines around each change, in the familiar `diff -u` layout. The result
+is plain text; colouring is left to the caller.
+*/
+func unifiedDiff(oldName, newName string, a, b []string, context int) []string {
+	lines := diffLines(a, b)
+
+	var changes []int
+	for i, l := range lines {
+		if l.op != diffEqual {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	out := []string{"--- " + oldName, "+++ " + newName}
+
+	for c := 0; c < len(changes); {
+		start := max(0, changes[c]-context)
+		end := min(len(lines), changes[c]+context+1)
+		// Merge following changes whose context overlaps this hunk.
+		for c++; c < len(changes) && changes[c]-context <= end; c++ {
+			end = min(len(lines), changes[c]+context+1)
+		}
+
+		// Line numbers at the start of the hunk.
+		oldLine, newLine := 1, 1
+		for _, l := range lines[:start] {
+			if l.op != diffInsert {
+				oldLine++
+			}
+			if l.op != diffDelete {
+				newLine++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, l := range lines[start:end] {
+			if l.op != diffInsert {
+				oldCount++
+			}
+			if l.op != diffDelete {
+				newCount++
+			}
+		}
+
+		out = append(out, fmt.Sprintf("@@ -%d,%d +%d,%d @@", oldLine, oldCount, newLine, newCount))
+		for _, l := range lines[start:end] {
+			switch l.op {
+			case diffEqual:
+				out = append(out, " "+l.text)
+			case diffDelete:
+				out = append(out, "-"+l.text)
+			case diffInsert:
+				out = append(out, "+"+l.text)
+			}
+		}
+	}
+	return out
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -3,41 +3,295 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
 )
 
+/*
+	NOTE:
+
+editNote opens path in the configured editor with the cursor at row/col
+(0-based, -1 for none). Encrypted notes always use the inbuilt editor so
+their plaintext only ever lives in memory.
+*/
+func (m model) editNote(path string, row, col int) (tea.Model, tea.Cmd) {
+	if isEncryptedFile(path) {
+		if !havePassphrase() {
+			return m.promptPassphrase(passEdit, path)
+		}
+		if _, err := readNote(path); errors.Is(err, errWrongPassphrase) {
+			clearPassphrase()
+			newM, cmd := m.promptPassphrase(passEdit, path)
+			return newM, tea.Batch(cmd, m.list.NewStatusMessage("Wrong passphrase"))
+		} else if err != nil && !os.IsNotExist(err) {
+			return m, m.list.NewStatusMessage("Open failed: " + err.Error())
+		}
+	}
+
+	if m.editor == "inbuilt" || isEncryptedFile(path) {
+		var editorCmd tea.Cmd
+		m, editorCmd = openInbuiltEditor(path, m)
+		if row >= 0 {
+			moveEditorCursor(&m.editorContent, row, col)
+		}
+		return m, editorCmd
+	}
+	return m, openInEditorAt(path, m.editor, row+1)
+}
+
+// editorMaxLines is the most lines the textarea will hold; longer notes would be cut off on save.
+const editorMaxLines = 10000
+
 func openInbuiltEditor(path string, m model) (model, tea.Cmd) {
-	content, err := os.ReadFile(path)
+	content, err := readNote(path)
 	if err != nil {
 		content = []byte{}
 	}
+	if bytes.Count(content, []byte("\n")) >= editorMaxLines {
+		return m, m.list.NewStatusMessage(fmt.Sprintf("Notes over %d lines are too long for the inbuilt editor", editorMaxLines))
+	}
 
 	ta := textarea.New()
-	ta.ShowLineNumbers = true
-	ta.SetWidth(m.width)
-	ta.SetHeight(m.height - 4)
+	ta.ShowLineNumbers = false
+	ta.MaxHeight = editorMaxLines
+	ta.MaxWidth = 0
+	ta.SetWidth(editorBufferWidth)
+	ta.SetHeight(max(1, m.height-4))
 	ta.SetValue(string(content))
 	ta.Focus()
 
 	m.editorMode = true
 	m.editorFile = path
 	m.editorContent = ta
+	// The textarea normalises tabs and line endings, so compare against what it holds.
+	m.editorSaved = ta.Value()
+	m.editorSnapshotted = false
+	m.editorQuitting = false
+	m.editorMsg = ""
+	m.editorUndo = editHistory{}
+	m.editorTop = 0
+	m.vimState = vimNormal
+	m.vimPending = nil
+	m.closeFind()
+	m.editorLexer = editorLexer(path)
+	m.editorSyntax, m.syntaxFor = nil, ""
+	syntaxCmd := m.refreshSyntax()
+	m.editorSession++
+	// Best effort: a second YapPad editing the same note still opens it.
+	m.editorLock.unlock()
+	m.editorLock, _ = tryLockNote(path)
+
+	m.editorPreview = m.previewDefault && isMarkdownFile(path)
+	if m.editorPreview {
+		m.resizeEditorPreview()
+		m.previewSeq++
+		return m, tea.Batch(m.autosaveTick(), syntaxCmd, m.renderEditorPreview())
+	}
+	return m, tea.Batch(m.autosaveTick(), syntaxCmd)
+}
+
+// editorDirty reports whether the buffer differs from what was last saved.
+func (m model) editorDirty() bool {
+	return m.editorContent.Value() != m.editorSaved
+}
+
+// autosaveTick schedules the next autosave for the current editor session, if autosave is on.
+func (m model) autosaveTick() tea.Cmd {
+	if m.autosave <= 0 {
+		return nil
+	}
+	session := m.editorSession
+	return tea.Tick(m.autosave, func(time.Time) tea.Msg {
+		return autosaveTickMsg{session: session}
+	})
+}
+
+// updateEditorMode handles a key press in the inbuilt editor.
+func (m model) updateEditorMode(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	before := m.editorContent.Value()
+	m, cmd := m.editorKey(msg)
+	if !m.editorMode {
+		return m, cmd
+	}
+	m.scrollEditor()
+	cmd = tea.Batch(cmd, m.refreshSyntax())
+	if m.editorPreview {
+		if m.editorContent.Value() != before {
+			cmd = tea.Batch(cmd, m.schedulePreview())
+		}
+		m.syncPreviewScroll()
+	}
+	return m, cmd
+}
+
+func (m model) editorKey(msg tea.KeyMsg) (model, tea.Cmd) {
+	m.editorMsg = ""
+	if m.editorQuitting {
+		switch msg.String() {
+		case "s", "S", "y", "Y":
+			return m.saveAndCloseEditor()
+		case "d", "D", "n", "N":
+			return m.closeEditor()
+		case "c", "C", "esc":
+			m.editorQuitting = false
+		}
+		if key.Matches(msg, m.keys.EditorClose) {
+			m.editorQuitting = false
+		}
+		return m, nil
+	}
+
+	if m.vimEnabled && !m.findMode {
+		var cmd tea.Cmd
+		var handled bool
+		if m, cmd, handled = m.vimKey(msg); handled {
+			return m, cmd
+		}
+	}
+
+	switch {
+	case key.Matches(msg, m.keys.EditorSave):
+		return m, m.saveEditor()
+	case key.Matches(msg, m.keys.EditorClose):
+		return m.quitEditor()
+	case key.Matches(msg, m.keys.EditorUndo):
+		m.undoEditor()
+		return m, nil
+	case key.Matches(msg, m.keys.EditorRedo):
+		m.redoEditor()
+		return m, nil
+	case key.Matches(msg, m.keys.EditorPreview):
+		return m.toggleEditorPreview()
+	case key.Matches(msg, m.keys.EditorFind):
+		return m.openFind(false)
+	case key.Matches(msg, m.keys.EditorReplace):
+		return m.openFind(true)
+	}
+
+	if m.findMode {
+		return m.updateFind(msg)
+	}
+	if m.vimEnabled && m.vimState != vimInsert {
+		return m, nil
+	}
+	return m.updateEditor(msg)
+}
+
+func (m model) saveEditor() tea.Cmd {
+	return saveEditorContent(m.editorFile, m.editorContent.Value(), false, true)
+}
+
+// quitEditor closes the editor, asking first if there are unsaved changes.
+func (m model) quitEditor() (model, tea.Cmd) {
+	if m.editorDirty() {
+		m.editorQuitting = true
+		return m, nil
+	}
+	return m.closeEditor()
+}
+
+// saveAndCloseEditor writes the buffer and closes the editor, staying open if the write fails.
+func (m model) saveAndCloseEditor() (model, tea.Cmd) {
+	if err := writeEditorContent(m.editorFile, m.editorContent.Value(), true); err != nil {
+		m.editorQuitting = false
+		m.editorMsg = "Save failed: " + err.Error()
+		return m, nil
+	}
+	return m.closeEditor()
+}
+
+func (m *model) undoEditor() {
+	if prev, ok := m.editorUndo.undoTo(currentEditState(m.editorContent)); ok {
+		restoreEditState(&m.editorContent, prev)
+	} else {
+		m.editorMsg = "Nothing to undo"
+	}
+}
+
+func (m *model) redoEditor() {
+	if next, ok := m.editorUndo.redoTo(currentEditState(m.editorContent)); ok {
+		restoreEditState(&m.editorContent, next)
+	} else {
+		m.editorMsg = "Nothing to redo"
+	}
+}
 
+// closeEditor leaves the inbuilt editor, dropping any unsaved changes.
+func (m model) closeEditor() (model, tea.Cmd) {
+	m.editorMode = false
+	m.editorQuitting = false
+	m.editorContent.Blur()
+	m.editorLock.unlock()
+	m.editorLock = nil
+	m.list.SetItems(m.listItems())
+	if m.showPreview {
+		m.loadingFile = true
+		return m, tea.Batch(m.spinner.Tick, m.loadFileOrImage(m.resolveFilePath(m.selectedFile)))
+	}
 	return m, nil
 }
 
-func saveEditorContent(path, content string) tea.Cmd {
+// moveEditorCursor places the textarea cursor at the given line and column.
+func moveEditorCursor(ta *textarea.Model, row, col int) {
+	row = max(0, min(row, ta.LineCount()-1))
+	for ta.Line() > row {
+		ta.CursorUp()
+	}
+	for ta.Line() < row {
+		before := ta.LineInfo()
+		ta.CursorDown()
+		if ta.Line() < row && ta.LineInfo() == before {
+			break
+		}
+	}
+	ta.SetCursor(col)
+}
+
+func saveEditorContent(path, content string, auto, snapshot bool) tea.Cmd {
 	return func() tea.Msg {
-		err := os.WriteFile(path, []byte(content), 0o644)
-		if err != nil {
-			return editorSavedMsg{}
+		return editorSavedMsg{path: path, content: content, auto: auto, err: writeEditorContent(path, content, snapshot)}
+	}
+}
+
+/*
+	NOTE:
+
+writeEditorContent saves the inbuilt editor's buffer. With snapshot set
+the previous version is kept in history first. Manual saves always keep
+one; autosaves only on the first write of an editor session, or every
+pause in typing would add a version and push real history past the cap.
+*/
+func writeEditorContent(path, content string, snapshot bool) error {
+	if isEncryptedFile(path) {
+		return saveEncryptedContent(path, content, snapshot)
+	}
+	if snapshot {
+		// History is best effort; it must never stop the note itself from being saved.
+		snapshotBeforeWrite(path, []byte(content))
+	}
+	return os.WriteFile(path, []byte(content), 0o644)
+}
+
+// saveEncryptedContent re-encrypts an edited note. History keeps the old ciphertext, never plaintext.
+func saveEncryptedContent(path, content string, snapshot bool) error {
+	if old, err := os.ReadFile(path); err == nil && len(old) > 0 {
+		if plain, err := decryptNote(old); err == nil && string(plain) == content {
+			return nil
+		}
+		if snapshot {
+			saveSnapshot(path, old)
 		}
-		return editorSavedMsg{}
 	}
+	return writeEncryptedNote(path, []byte(content))
 }
 
 func getEditor() string {
@@ -47,7 +301,34 @@ func getEditor() string {
 	return "nvim"
 }
 
-func openInEditor(path, editor string) tea.Cmd {
+// openInEditorAt opens path in an external editor, jumping to line (1-based) when the editor supports it.
+func openInEditorAt(path, editor string, line int) tea.Cmd {
+	// Keep the pre-edit version in history if the editor changed the file.
+	original, readErr := os.ReadFile(path)
+	lock, _ := tryLockNote(path)
+
+	return tea.ExecProcess(editorCommand(path, editor, line), func(err error) tea.Msg {
+		lock.unlock()
+		if readErr == nil {
+			snapshotIfEdited(path, original)
+		}
+		return fileEditedMsg{err: err}
+	})
+}
+
+// runEditor runs an external editor on path outside of the TUI, for CLI commands.
+func runEditor(path, editor string, line int) error {
+	original, readErr := os.ReadFile(path)
+	if err := editorCommand(path, editor, line).Run(); err != nil {
+		return err
+	}
+	if readErr == nil {
+		snapshotIfEdited(path, original)
+	}
+	return nil
+}
+
+func editorCommand(path, editor string, line int) *exec.Cmd {
 	var e string
 	switch editor {
 	case "nano":
@@ -58,11 +339,19 @@ func openInEditor(path, editor string) tea.Cmd {
 		e = getEditor()
 	}
 
-	cmd := exec.Command(e, path)
+	args := []string{path}
+	if line > 0 {
+		switch filepath.Base(e) {
+		case "hx", "helix":
+			args = []string{fmt.Sprintf("%s:%d", path, line)}
+		case "vi", "vim", "nvim", "nano", "emacs", "micro", "kak":
+			args = []string{fmt.Sprintf("+%d", line), path}
+		}
+	}
+
+	cmd := exec.Command(e, args...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	return tea.ExecProcess(cmd, func(err error) tea.Msg {
-		return fileEditedMsg{err: err}
-	})
+	return cmd
 }
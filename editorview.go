@@ -0,0 +1,238 @@
+// NOTE: Drawing the inbuilt editor. The textarea does the editing; this renders its buffer so
+// syntax, search matches and selections can be coloured.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+)
+
+/*
+	NOTE:
+
+The textarea is given a very wide, unwrapped buffer so cursor movement
+works on whole lines. Wrapping to the screen happens here instead, which
+means up/down step over a wrapped line in one go.
+*/
+const editorBufferWidth = 1 << 15
+
+type highlight int
+
+const (
+	hlNone highlight = iota
+	hlMatch
+	hlCurrentMatch
+	hlSelection
+)
+
+// editorCell is how one rune of the buffer is drawn.
+type editorCell struct {
+	fg lipgloss.Color
+	hl highlight
+}
+
+func (m model) editorWidth() int {
+	if m.editorPreview {
+		return m.width / 2
+	}
+	return m.width
+}
+
+func (m model) editorHeight() int {
+	h := m.height - 4
+	if m.findMode {
+		h -= 2
+	}
+	return max(1, h)
+}
+
+func (m model) editorGutterWidth() int {
+	return len(strconv.Itoa(m.editorContent.LineCount())) + 2
+}
+
+// editorTextWidth is how many cells of text fit on a row; one is kept free for the cursor at line end.
+func (m model) editorTextWidth() int {
+	return max(1, m.editorWidth()-m.editorGutterWidth()-1)
+}
+
+// editorCursor returns the cursor's logical line and rune column.
+func (m model) editorCursor() (int, int) {
+	li := m.editorContent.LineInfo()
+	return m.editorContent.Line(), li.StartColumn + li.ColumnOffset
+}
+
+// wrapLine splits line into screen rows at most width cells wide, as [start, end) rune ranges.
+func wrapLine(line []rune, width int) [][2]int {
+	var rows [][2]int
+	start, w := 0, 0
+	for i, r := range line {
+		rw := runewidth.RuneWidth(r)
+		if w+rw > width && i > start {
+			rows = append(rows, [2]int{start, i})
+			start, w = i, 0
+		}
+		w += rw
+	}
+	return append(rows, [2]int{start, len(line)})
+}
+
+// cursorRow returns which of rows holds rune column col.
+func cursorRow(rows [][2]int, col int) int {
+	for i, r := range rows {
+		if col < r[1] {
+			return i
+		}
+	}
+	return len(rows) - 1
+}
+
+// scrollEditor moves the editor's first visible line just enough to keep the cursor on screen.
+func (m *model) scrollEditor() {
+	lines := strings.Split(m.editorContent.Value(), "\n")
+	row, col := m.editorCursor()
+	width, height := m.editorTextWidth(), m.editorHeight()
+
+	m.editorTop = min(m.editorTop, len(lines)-1)
+	if row <= m.editorTop {
+		m.editorTop = row
+		return
+	}
+
+	used := cursorRow(wrapLine([]rune(lines[row]), width), col) + 1
+	top := row
+	for top > 0 {
+		rows := len(wrapLine([]rune(lines[top-1]), width))
+		if used+rows > height {
+			break
+		}
+		used += rows
+		top--
+	}
+	m.editorTop = max(m.editorTop, top)
+}
+
+// editorCells works out how every rune of line row should be drawn.
+func (m model) editorCells(row int, line []rune) []editorCell {
+	cells := make([]editorCell, len(line))
+	if row < len(m.editorSyntax) {
+		for c, color := range m.editorSyntax[row] {
+			if c < len(cells) {
+				cells[c].fg = color
+			}
+		}
+	}
+	if m.vimState == vimVisual || m.vimState == vimVisualLine {
+		b := m.vimBuffer()
+		from, to := m.vimSelection(b)
+		start := offsetOf(b.text, row, 0)
+		for c := range cells {
+			if off := start + c; off >= from && off < to {
+				cells[c].hl = hlSelection
+			}
+		}
+	}
+	for i, match := range m.findMatches {
+		if match.row != row {
+			continue
+		}
+		hl := hlMatch
+		if i == m.findIdx {
+			hl = hlCurrentMatch
+		}
+		for c := match.start; c < min(match.end, len(cells)); c++ {
+			cells[c].hl = hl
+		}
+	}
+	return cells
+}
+
+func (m model) cellStyle(c editorCell) lipgloss.Style {
+	s := lipgloss.NewStyle()
+	if c.fg != "" {
+		s = s.Foreground(c.fg)
+	}
+	switch c.hl {
+	case hlMatch:
+		s = s.Background(m.theme.Muted)
+	case hlCurrentMatch:
+		s = s.Background(m.theme.Accent).Foreground(lipgloss.Color("230"))
+	case hlSelection:
+		s = s.Background(m.theme.Primary).Foreground(lipgloss.Color("230"))
+	}
+	return s
+}
+
+// renderCells draws a run of runes, grouping neighbours that share a style.
+func (m model) renderCells(line []rune, cells []editorCell, cursor int) string {
+	var b strings.Builder
+	cursorStyle := lipgloss.NewStyle().Reverse(true)
+	for i := 0; i < len(line); {
+		if i == cursor {
+			b.WriteString(m.cellStyle(cells[i]).Inherit(cursorStyle).Render(string(line[i])))
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(line) && cells[j] == cells[i] && j != cursor {
+			j++
+		}
+		b.WriteString(m.cellStyle(cells[i]).Render(string(line[i:j])))
+		i = j
+	}
+	if cursor == len(line) {
+		b.WriteString(cursorStyle.Render(" "))
+	}
+	return b.String()
+}
+
+// editorView renders the visible part of the buffer with line numbers.
+func (m model) editorView() string {
+	lines := strings.Split(m.editorContent.Value(), "\n")
+	curRow, curCol := m.editorCursor()
+	width, height := m.editorTextWidth(), m.editorHeight()
+	gutter := m.editorGutterWidth()
+
+	numStyle := lipgloss.NewStyle().Foreground(m.theme.Muted)
+	curNumStyle := lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true)
+
+	var out []string
+	for row := m.editorTop; row < len(lines) && len(out) < height; row++ {
+		line := []rune(lines[row])
+		cells := m.editorCells(row, line)
+		spans := wrapLine(line, width)
+		cursorSpan := -1
+		if row == curRow {
+			cursorSpan = cursorRow(spans, curCol)
+		}
+
+		for i, span := range spans {
+			num := strings.Repeat(" ", gutter)
+			if i == 0 {
+				num = fmt.Sprintf(" %*d ", gutter-2, row+1)
+			}
+			if row == curRow {
+				num = curNumStyle.Render(num)
+			} else {
+				num = numStyle.Render(num)
+			}
+
+			cursor := -1
+			if i == cursorSpan {
+				cursor = curCol - span[0]
+			}
+			out = append(out, num+m.renderCells(line[span[0]:span[1]], cells[span[0]:span[1]], cursor))
+			if len(out) == height {
+				break
+			}
+		}
+	}
+	for len(out) < height {
+		out = append(out, "")
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,576 @@
+// NOTE: `yap export html`, a static site built from the vault
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Root}}{{.Stylesheet}}">
+</head>
+<body>
+<nav><a href="{{.Root}}{{.Index}}">All notes</a></nav>
+<main>
+{{- if .Description}}
+<p class="summary">{{.Description}}</p>
+{{- end}}
+{{.Body}}
+</main>
+</body>
+</html>
+`))
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Stylesheet}}">
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+<p class="sort">Sorted by {{.Sort}}</p>
+<ul class="index">
+{{- range .Notes}}
+<li><a href="{{.URL}}">{{.Title}}</a>{{if .Summary}}<span class="summary">{{.Summary}}</span>{{end}}<time>{{.Modified}}</time></li>
+{{- end}}
+</ul>
+</main>
+</body>
+</html>
+`))
+
+const siteCSS = `:root {
+  --primary: %s;
+  --secondary: %s;
+  --border: %s;
+  --accent: %s;
+  --muted: %s;
+  --more-muted: %s;
+  --text: %s;
+  --subtext: %s;
+  --background: #121212;
+}
+body { background: var(--background); color: var(--text); font: 16px/1.6 system-ui, sans-serif; margin: 0; }
+main, nav { max-width: 48rem; margin: 0 auto; padding: 1rem 1.5rem; }
+nav { border-bottom: 1px solid var(--border); }
+h1, h2, h3, h4, h5, h6 { color: var(--primary); line-height: 1.25; }
+a { color: var(--accent); }
+a:visited { color: var(--secondary); }
+code, pre { font-family: ui-monospace, monospace; background: var(--more-muted); }
+code { padding: 0.1em 0.3em; border-radius: 3px; }
+pre { padding: 0.8rem 1rem; overflow-x: auto; border-left: 3px solid var(--primary); }
+pre code { padding: 0; background: none; }
+blockquote { margin-left: 0; padding-left: 1rem; border-left: 3px solid var(--muted); color: var(--subtext); }
+table { border-collapse: collapse; }
+th, td { border: 1px solid var(--border); padding: 0.3rem 0.6rem; }
+hr { border: 0; border-top: 1px solid var(--border); }
+img { max-width: 100%%; }
+.summary, .sort, time { color: var(--subtext); }
+.index { list-style: none; padding: 0; }
+.index li { padding: 0.5rem 0; border-bottom: 1px solid var(--more-muted); }
+.index .summary { display: block; }
+.index time { display: block; font-size: 0.85em; color: var(--muted); }
+`
+
+// ansiColors is the standard xterm palette for ANSI colours 0-15.
+var ansiColors = [16]string{
+	"#000000", "#800000", "#008000", "#808000", "#000080", "#800080", "#008080", "#c0c0c0",
+	"#808080", "#ff0000", "#00ff00", "#ffff00", "#0000ff", "#ff00ff", "#00ffff", "#ffffff",
+}
+
+// cssColor turns a theme colour, hex or ANSI 0-255, into a CSS hex colour.
+func cssColor(c lipgloss.Color) string {
+	s := string(c)
+	if strings.HasPrefix(s, "#") {
+		return s
+	}
+	n, err := strconv.Atoi(s)
+	switch {
+	case err != nil || n < 0 || n > 255:
+		return "inherit"
+	case n < 16:
+		return ansiColors[n]
+	case n < 232:
+		// 6x6x6 colour cube
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + 40*v
+		}
+		n -= 16
+		return fmt.Sprintf("#%02x%02x%02x", level(n/36), level(n/6%6), level(n%6))
+	}
+	gray := 8 + 10*(n-232)
+	return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+}
+
+func themeCSS(t Theme) string {
+	return fmt.Sprintf(siteCSS, cssColor(t.Primary), cssColor(t.Secondary), cssColor(t.Border), cssColor(t.Accent),
+		cssColor(t.Muted), cssColor(t.MoreMuted), cssColor(t.Text), cssColor(t.SubText))
+}
+
+const (
+	// siteIndex is the generated list of notes, at the root so the site opens on it.
+	siteIndex = "index.html"
+	// siteStylesheet lives under _yappad/ so no vault file can be copied over it.
+	siteStylesheet = "_yappad/style.css"
+)
+
+// siteExport holds the state of one export: which notes become pages and which files were copied.
+type siteExport struct {
+	out     string
+	titles  map[string]bool   // every note in the vault, for resolving links
+	pages   map[string]bool   // notes exported as pages
+	urls    map[string]string // where each page is written, see assignURLs
+	outputs map[string]bool   // every path written in the site, lowercased
+	shared  map[string]bool   // everything picked for export, pages and files
+	copied  map[string]bool   // vault files copied across as they are
+	skipped []string
+	renamed []string
+	md      goldmark.Markdown
+}
+
+// pageURL is where note title ends up in the site, relative to its root.
+func pageURL(title string) string {
+	title = filepath.ToSlash(title)
+	if isMarkdownFile(title) {
+		return strings.TrimSuffix(title, path.Ext(title)) + ".html"
+	}
+	return title + ".html"
+}
+
+/*
+	NOTE:
+
+assignURLs picks each page's path in the site. Usually that's pageURL, but
+a note can map onto a generated file (index.md onto index.html) or onto
+another note's page (x.md and x.markdown both onto x.html); the later one
+then gets a numbered name, x-2.html, and is reported rather than written
+over. Names are compared ignoring case, for case-insensitive filesystems.
+*/
+func (e *siteExport) assignURLs(notes []item) {
+	taken := e.outputs
+	taken[siteIndex] = true
+	taken[strings.ToLower(siteStylesheet)] = true
+	for _, n := range notes {
+		want := pageURL(n.title)
+		u := want
+		for i := 2; taken[strings.ToLower(u)]; i++ {
+			u = strings.TrimSuffix(want, ".html") + "-" + strconv.Itoa(i) + ".html"
+		}
+		if u != want {
+			e.renamed = append(e.renamed, fmt.Sprintf("%s as %s (%s is taken)", n.title, u, want))
+		}
+		taken[strings.ToLower(u)] = true
+		e.urls[n.title] = u
+	}
+}
+
+// relURL is a link from the page at from to to, both relative to the site root.
+func relURL(from, to string) string {
+	rel, err := filepath.Rel(filepath.Dir(filepath.FromSlash(from)), filepath.FromSlash(to))
+	if err != nil {
+		return to
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i, p := range parts {
+		if p != ".." {
+			parts[i] = url.PathEscape(p)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// siteRoot is the relative path from the page at page back to the site root.
+func siteRoot(page string) string {
+	return strings.Repeat("../", strings.Count(page, "/"))
+}
+
+/*
+	NOTE:
+
+cmdExport writes the vault, or just the folders and notes given, as a
+static site: markdown through goldmark, other text notes as preformatted
+text, everything else copied as is. Links between exported notes point at
+their pages and linked images and attachments are copied along; links to
+notes left out of the export are kept as plain text.
+*/
+func cmdExport(cfg Config, args []string) error {
+	if len(args) == 0 || args[0] != "html" {
+		return errors.New("usage: yap export html [folder or note...] [--out dir] [--sort mode]")
+	}
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	outFlag := fs.String("out", "site", "")
+	sortFlag := fs.String("sort", "modified", "")
+	only, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+	sMode, err := parseSortMode(*sortFlag)
+	if err != nil {
+		return err
+	}
+	out, err := filepath.Abs(*outFlag)
+	if err != nil {
+		return err
+	}
+	vault, err := filepath.Abs(vaultDir)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(vault, out); err == nil && !strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("%s is inside the vault; pick an output directory outside it", out)
+	}
+
+	var prefixes []string
+	for _, name := range only {
+		p, err := notePath(name)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(p); err != nil {
+			if _, err := resolveNote(name); err != nil {
+				return fmt.Errorf("no such note or folder: %s", name)
+			}
+		}
+		rel, _ := filepath.Rel(vaultDir, p)
+		prefixes = append(prefixes, rel)
+	}
+
+	e := &siteExport{
+		out:     out,
+		titles:  map[string]bool{},
+		pages:   map[string]bool{},
+		urls:    map[string]string{},
+		outputs: map[string]bool{},
+		shared:  map[string]bool{},
+		copied:  map[string]bool{},
+		md: goldmark.New(
+			goldmark.WithExtensions(extension.GFM),
+			goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		),
+	}
+	return e.run(sMode, prefixes, getTheme(cfg.Theme))
+}
+
+// included reports whether title is one of prefixes, or inside one of them; no prefixes means everything.
+func included(title string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if title == p || title == p+".md" || strings.HasPrefix(title, p+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *siteExport) run(sMode sortMode, prefixes []string, theme Theme) error {
+	items := listFiles(sMode)
+	var notes []item
+	var files []string
+	for _, it := range items {
+		i := it.(item)
+		e.titles[i.title] = true
+		if !included(i.title, prefixes) {
+			continue
+		}
+		full := filepath.Join(vaultDir, i.title)
+		switch {
+		case isEncryptedFile(full):
+			e.skipped = append(e.skipped, i.title+" (encrypted)")
+		case isImageFile(full):
+			files = append(files, i.title)
+			e.shared[i.title] = true
+		default:
+			content, err := os.ReadFile(full)
+			if err != nil {
+				e.skipped = append(e.skipped, fmt.Sprintf("%s (%v)", i.title, err))
+				continue
+			}
+			if _, binary := binaryType(full, content); binary {
+				files = append(files, i.title)
+				e.shared[i.title] = true
+				continue
+			}
+			notes = append(notes, i)
+			e.pages[i.title] = true
+			e.shared[i.title] = true
+		}
+	}
+
+	e.assignURLs(notes)
+
+	stylesheet := filepath.Join(e.out, filepath.FromSlash(siteStylesheet))
+	if err := os.MkdirAll(filepath.Dir(stylesheet), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(stylesheet, []byte(themeCSS(theme)), 0o644); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := e.copyFile(f); err != nil {
+			e.skipped = append(e.skipped, fmt.Sprintf("%s (%v)", f, err))
+		}
+	}
+
+	type indexEntry struct {
+		URL, Title, Summary, Modified string
+	}
+	var index []indexEntry
+	for _, n := range notes {
+		if err := e.writePage(n.title); err != nil {
+			return err
+		}
+		index = append(index, indexEntry{
+			URL:      relURL(siteIndex, e.urls[n.title]),
+			Title:    filepath.ToSlash(n.title),
+			Summary:  readMetaDesc(filepath.Join(vaultDir, n.title)),
+			Modified: n.modTime.Format(time.DateOnly),
+		})
+	}
+
+	var buf bytes.Buffer
+	err := indexTemplate.Execute(&buf, map[string]any{
+		"Title":      filepath.Base(vaultDir),
+		"Sort":       sMode.String(),
+		"Notes":      index,
+		"Stylesheet": siteStylesheet,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(e.out, siteIndex), buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %d notes and %d files to %s\n", len(notes), len(e.copied), e.out)
+	for _, s := range e.renamed {
+		fmt.Printf("  exported %s\n", s)
+	}
+	for _, s := range e.skipped {
+		fmt.Printf("  skipped %s\n", s)
+	}
+	return nil
+}
+
+// writePage renders the note title into its page.
+func (e *siteExport) writePage(title string) error {
+	full := filepath.Join(vaultDir, title)
+	content, err := os.ReadFile(full)
+	if err != nil {
+		return err
+	}
+	page := e.urls[title]
+
+	var body bytes.Buffer
+	if isMarkdownFile(title) {
+		src := []byte(e.wikiToMarkdown(string(content)))
+		doc := e.md.Parser().Parse(text.NewReader(src))
+		e.rewriteLinks(doc, title)
+		if err := e.md.Renderer().Render(&body, src, doc); err != nil {
+			return err
+		}
+	} else {
+		body.WriteString("<pre><code>" + template.HTMLEscapeString(string(content)) + "</code></pre>")
+	}
+
+	var buf bytes.Buffer
+	err = pageTemplate.Execute(&buf, map[string]any{
+		"Title":       filepath.ToSlash(title),
+		"Root":        siteRoot(page),
+		"Index":       siteIndex,
+		"Stylesheet":  siteStylesheet,
+		"Description": readMetaDesc(full),
+		"Body":        template.HTML(body.String()),
+	})
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(e.out, filepath.FromSlash(page))
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, buf.Bytes(), 0o644)
+}
+
+// wikiToMarkdown turns [[note|alias]] into a markdown link from the vault root, so it's rewritten like any other.
+func (e *siteExport) wikiToMarkdown(content string) string {
+	return wikiLinkRe.ReplaceAllStringFunc(content, func(m string) string {
+		inner := wikiLinkRe.FindStringSubmatch(m)[1]
+		label := inner
+		if i := strings.Index(inner, "|"); i >= 0 {
+			label = strings.TrimSpace(inner[i+1:])
+		}
+		links := parseLinks(m)
+		if len(links) == 0 {
+			return m
+		}
+		target := resolveLink(links[0], "", e.titles)
+		if target == "" {
+			return label
+		}
+		return fmt.Sprintf("[%s](</%s>)", label, filepath.ToSlash(target))
+	})
+}
+
+/*
+	NOTE:
+
+rewriteLinks points links and images in a note's document at the exported
+site. Links to notes outside the export have no page to go to, so they are
+unwrapped into their text.
+*/
+func (e *siteExport) rewriteLinks(doc ast.Node, title string) {
+	var dead []*ast.Link
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Link:
+			dest, ok := e.rewrite(title, string(n.Destination), false)
+			if !ok {
+				dead = append(dead, n)
+			}
+			n.Destination = []byte(dest)
+		case *ast.Image:
+			dest, _ := e.rewrite(title, string(n.Destination), true)
+			n.Destination = []byte(dest)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, link := range dead {
+		parent := link.Parent()
+		for c := link.FirstChild(); c != nil; {
+			next := c.NextSibling()
+			parent.InsertBefore(parent, link, c)
+			c = next
+		}
+		parent.RemoveChild(parent, link)
+	}
+}
+
+// rewrite maps a link destination written in note from to its place in the site. It reports false for a link to a note that isn't exported.
+func (e *siteExport) rewrite(from, dest string, image bool) (string, bool) {
+	if dest == "" || strings.Contains(dest, "://") || strings.HasPrefix(dest, "mailto:") || strings.HasPrefix(dest, "#") {
+		return dest, true
+	}
+	target, fragment := dest, ""
+	if i := strings.Index(dest, "#"); i >= 0 {
+		target, fragment = dest[:i], dest[i:]
+	}
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+	page := e.urls[from]
+
+	if !image {
+		if note := resolveLink(rawLink{target: target}, from, e.titles); e.pages[note] {
+			return relURL(page, e.urls[note]) + fragment, true
+		}
+	}
+
+	// Images and attachments are copied over, even from hidden folders like .attachments.
+	rel := filepath.Clean(filepath.Join(filepath.Dir(from), filepath.FromSlash(target)))
+	if strings.HasPrefix(target, "/") {
+		rel = filepath.Clean(strings.TrimPrefix(filepath.FromSlash(target), string(filepath.Separator)))
+	}
+	full, err := notePath(rel)
+	if err != nil || isEncryptedFile(full) {
+		return dest, true
+	}
+	if info, err := os.Stat(full); err != nil || info.IsDir() {
+		return dest, true
+	}
+	if (e.titles[rel] && !e.shared[rel]) || privatePath(rel) {
+		// A note or file that was left out of the export, or YapPad's own data; don't publish it through a link.
+		return dest, false
+	}
+	if err := e.copyFile(rel); err != nil {
+		e.skipped = append(e.skipped, fmt.Sprintf("%s (%v)", rel, err))
+		return dest, true
+	}
+	return relURL(page, filepath.ToSlash(rel)) + fragment, true
+}
+
+// privateDirs are YapPad's own folders in the vault; nothing in them is ever published.
+var privateDirs = map[string]bool{
+	".trash":     true,
+	".history":   true,
+	".locks":     true,
+	".templates": true,
+	".metadesc":  true,
+}
+
+// privatePath reports whether the vault path rel is YapPad's data rather than the user's: the metadata index, its temporary files, or anything in privateDirs.
+func privatePath(rel string) bool {
+	first, _, _ := strings.Cut(filepath.ToSlash(rel), "/")
+	return privateDirs[first] || strings.HasPrefix(first, ".yappad-")
+}
+
+// copyFile copies the vault file rel into the site at the same path, once.
+func (e *siteExport) copyFile(rel string) error {
+	if e.copied[rel] {
+		return nil
+	}
+	out := strings.ToLower(filepath.ToSlash(rel))
+	if e.outputs[out] {
+		return fmt.Errorf("%s is already a page of the site", filepath.ToSlash(rel))
+	}
+	src, err := os.Open(filepath.Join(vaultDir, rel))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dstPath := filepath.Join(e.out, rel)
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0o755); err != nil {
+		return err
+	}
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	e.copied[rel] = true
+	e.outputs[out] = true
+	return nil
+}
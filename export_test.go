@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runExport(t *testing.T) string {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "site")
+	if err := cmdExport(Config{}, []string{"html", "--out", out}); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func readSite(t *testing.T, out, rel string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(rel)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestExportReservedNames(t *testing.T) {
+	writeVault(t, map[string]string{
+		"index.md":    "# My real index\n\nSee [[x]].",
+		"style.css":   "body { color: hotpink; }",
+		"x.md":        "# From md",
+		"x.markdown":  "# From markdown",
+		"notes/a.md":  "# A",
+		"notes/ok.md": "[home](../index.md)",
+	})
+	out := runExport(t)
+
+	index := readSite(t, out, "index.html")
+	if strings.Contains(index, "My real index") {
+		t.Error("index.html holds the note, want the generated index")
+	}
+	for _, page := range []string{"index-2.html", "style.css.html", "notes/a.html"} {
+		readSite(t, out, page)
+	}
+	if got := readSite(t, out, "index-2.html"); !strings.Contains(got, "My real index") {
+		t.Errorf("index-2.html = %q, want the index.md note", got)
+	}
+	if css := readSite(t, out, siteStylesheet); strings.Contains(css, "hotpink") {
+		t.Error("the vault's style.css replaced the generated stylesheet")
+	}
+
+	// x.md and x.markdown each keep a page, and links go to the renamed one.
+	x, x2 := readSite(t, out, "x.html"), readSite(t, out, "x-2.html")
+	if strings.Contains(x, "From md") == strings.Contains(x2, "From md") {
+		t.Errorf("x.html and x-2.html should hold one note each:\n%s\n%s", x, x2)
+	}
+	if got := readSite(t, out, "notes/ok.html"); !strings.Contains(got, `href="../index-2.html"`) {
+		t.Errorf("link to index.md = %q, want it pointing at ../index-2.html", got)
+	}
+}
@@ -4,6 +4,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
@@ -56,25 +57,23 @@ Images are NOT handled here — they use renderImage() instead.
 */
 func readFile(path string) tea.Cmd {
 	return func() tea.Msg {
-		content, err := os.ReadFile(path)
+		content, err := readNote(path)
+		if errors.Is(err, errNoPassphrase) {
+			return passphraseNeededMsg{path: path}
+		}
+		if errors.Is(err, errWrongPassphrase) {
+			return fileLoadedMsg{content: "[Encrypted note: wrong passphrase. Press enter to try another]"}
+		}
 		if err != nil {
 			return fileLoadedMsg{content: "Error reading file"}
 		}
 
-		ext := strings.ToLower(filepath.Ext(path))
-		switch ext {
-		case ".md", ".markdown", ".txt", ".go", ".c", ".cpp", ".h", ".py", ".js", ".ts", ".html", ".css", ".json", ".yaml", ".yml", ".toml", ".sh", ".mod", ".sum":
-		default:
-			buffer := make([]byte, 512)
-			copy(buffer, content)
-			contentType := http.DetectContentType(buffer)
-			if strings.HasPrefix(contentType, "audio/") ||
-				strings.HasPrefix(contentType, "video/") ||
-				contentType == "application/octet-stream" {
-				return fileLoadedMsg{content: fmt.Sprintf("[Binary file: %s]", contentType)}
-			}
+		if contentType, binary := binaryType(path, content); binary {
+			return fileLoadedMsg{content: fmt.Sprintf("[Binary file: %s]", contentType)}
 		}
 
+		// secret.md.enc is shown like secret.md
+		ext := strings.ToLower(filepath.Ext(plainName(path)))
 		if ext == ".md" || ext == ".markdown" {
 			return fileLoadedMsg{content: renderMarkdown(string(content))}
 		}
@@ -88,48 +87,86 @@ func readFile(path string) tea.Cmd {
 	}
 }
 
-// NOTE: Made for adding description to an item
-func writeMetaDesc(filePath, desc string) error {
-	if desc == "" {
-		return nil
+// binaryType reports whether content is audio, video or other binary data rather than text, and what it is.
+func binaryType(path string, content []byte) (string, bool) {
+	switch strings.ToLower(filepath.Ext(plainName(path))) {
+	case ".md", ".markdown", ".txt", ".go", ".c", ".cpp", ".h", ".py", ".js", ".ts", ".html", ".css", ".json", ".yaml", ".yml", ".toml", ".sh", ".mod", ".sum":
+		return "", false
 	}
-	metaDir := filepath.Join(vaultDir, ".metadesc")
-	if err := os.MkdirAll(metaDir, 0o755); err != nil {
-		return err
+	buffer := make([]byte, 512)
+	copy(buffer, content)
+	contentType := http.DetectContentType(buffer)
+	if strings.HasPrefix(contentType, "audio/") ||
+		strings.HasPrefix(contentType, "video/") ||
+		contentType == "application/octet-stream" {
+		return contentType, true
 	}
-	rel, err := filepath.Rel(vaultDir, filePath)
-	if err != nil {
-		rel = filepath.Base(filePath)
-	}
-	key := strings.ReplaceAll(rel, string(filepath.Separator), "__")
-	metaPath := filepath.Join(metaDir, key+".meta")
-	return os.WriteFile(metaPath, []byte(desc), 0o644)
+	return "", false
 }
 
-func readMetaDesc(filePath string) string {
-	metaDir := filepath.Join(vaultDir, ".metadesc")
-	rel, err := filepath.Rel(vaultDir, filePath)
-	if err != nil {
-		rel = filepath.Base(filePath)
+/*
+	NOTE:
+
+Links and tags parsed from a note are cached per file and only re-parsed
+when the file's modification time changes, so scanning the vault on every
+listFiles call doesn't re-read every note.
+*/
+type noteInfo struct {
+	modTime time.Time
+	links   []rawLink
+	tags    []string
+}
+
+var (
+	noteCache   = map[string]noteInfo{}
+	noteCacheMu sync.Mutex
+)
+
+func scanNote(path string, modTime time.Time) noteInfo {
+	noteCacheMu.Lock()
+	cached, ok := noteCache[path]
+	noteCacheMu.Unlock()
+	if ok && cached.modTime.Equal(modTime) {
+		return cached
 	}
-	key := strings.ReplaceAll(rel, string(filepath.Separator), "__")
-	metaPath := filepath.Join(metaDir, key+".meta")
-	data, err := os.ReadFile(metaPath)
-	if err != nil {
-		return ""
+
+	info := noteInfo{modTime: modTime}
+	if !isImageFile(path) && !isEncryptedFile(path) {
+		if data, err := os.ReadFile(path); err == nil && !isBinaryContent(data) {
+			info.links = parseLinks(string(data))
+			info.tags = parseTags(string(data))
+		}
 	}
-	return strings.TrimSpace(string(data))
+
+	noteCacheMu.Lock()
+	noteCache[path] = info
+	noteCacheMu.Unlock()
+	return info
 }
 
-func deleteMetaDesc(filePath string) {
-	metaDir := filepath.Join(vaultDir, ".metadesc")
-	rel, err := filepath.Rel(vaultDir, filePath)
-	if err != nil {
-		rel = filepath.Base(filePath)
+func filterPinned(items []list.Item) []list.Item {
+	var pinned []list.Item
+	for _, it := range items {
+		if it.(item).pinned {
+			pinned = append(pinned, it)
+		}
 	}
-	key := strings.ReplaceAll(rel, string(filepath.Separator), "__")
-	metaPath := filepath.Join(metaDir, key+".meta")
-	os.Remove(metaPath)
+	return pinned
+}
+
+// renameNote moves a note together with its description and history.
+func renameNote(oldPath, newPath string) error {
+	if _, err := os.Stat(newPath); err == nil && oldPath != newPath {
+		return fmt.Errorf("%s already exists", filepath.Base(newPath))
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	moveHistory(oldPath, newPath)
+	return moveMeta(oldPath, newPath)
 }
 
 func listFiles(sMode sortMode) []list.Item {
@@ -137,6 +174,8 @@ func listFiles(sMode sortMode) []list.Item {
 
 	var searchDir string
 	searchDir = vaultDir
+	// The metadata index is read once for the whole listing.
+	notesMeta := allNoteMeta()
 
 	filepath.WalkDir(searchDir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -171,10 +210,10 @@ func listFiles(sMode sortMode) []list.Item {
 		}
 		modStr := modTime.Format(time.RFC822)
 
-		customDesc := readMetaDesc(path)
+		meta := notesMeta[metaKey(path)]
 		var desc string
-		if customDesc != "" {
-			desc = customDesc
+		if meta.Description != "" {
+			desc = meta.Description
 		} else {
 			desc = "Modified: " + modStr
 		}
@@ -187,31 +226,39 @@ func listFiles(sMode sortMode) []list.Item {
 			// desc:    "Modified: " + modStr,
 			modTime: modTime,
 			creTime: creTime,
+			tags:    scanNote(path, modTime).tags,
+			pinned:  meta.Pinned,
 		})
 		return nil
 	})
 
 	sort.Slice(items, func(i, j int) bool {
-		itemI := items[i].(item)
-		itemJ := items[j].(item)
-
-		switch sMode {
-		case sortModifiedDesc:
-			return itemI.modTime.After(itemJ.modTime)
-		case sortModifiedAsc:
-			return itemI.modTime.Before(itemJ.modTime)
-		case sortCreatedDesc:
-			return itemI.creTime.After(itemJ.creTime)
-		case sortCreatedAsc:
-			return itemI.creTime.Before(itemJ.creTime)
-		case sortNameDesc:
-			return strings.ToLower(itemI.title) > strings.ToLower(itemJ.title)
-		case sortNameAsc:
-			return strings.ToLower(itemI.title) < strings.ToLower(itemJ.title)
-		default:
-			return itemI.modTime.After(itemJ.modTime)
-		}
+		return itemLess(items[i].(item), items[j].(item), sMode)
 	})
 
 	return items
 }
+
+// itemLess reports whether itemI sorts before itemJ in sort mode sMode.
+func itemLess(itemI, itemJ item, sMode sortMode) bool {
+	// Pinned notes come first whatever the sort.
+	if itemI.pinned != itemJ.pinned {
+		return itemI.pinned
+	}
+	switch sMode {
+	case sortModifiedDesc:
+		return itemI.modTime.After(itemJ.modTime)
+	case sortModifiedAsc:
+		return itemI.modTime.Before(itemJ.modTime)
+	case sortCreatedDesc:
+		return itemI.creTime.After(itemJ.creTime)
+	case sortCreatedAsc:
+		return itemI.creTime.Before(itemJ.creTime)
+	case sortNameDesc:
+		return strings.ToLower(itemI.title) > strings.ToLower(itemJ.title)
+	case sortNameAsc:
+		return strings.ToLower(itemI.title) < strings.ToLower(itemJ.title)
+	default:
+		return itemI.modTime.After(itemJ.modTime)
+	}
+}
@@ -0,0 +1,284 @@
+// NOTE: Find and replace in the inbuilt editor
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// maxFindMatches caps how many matches are tracked, so a one-letter query on a huge note stays quick.
+const maxFindMatches = 10000
+
+// editorMatch is a match on one line of the buffer, in rune columns [start, end).
+type editorMatch struct {
+	row, start, end int
+}
+
+// findRegexp compiles the find query, quoting it unless regex mode is on.
+func findRegexp(query string, regex, caseSensitive bool) (*regexp.Regexp, error) {
+	pattern := query
+	if !regex {
+		pattern = regexp.QuoteMeta(query)
+	}
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+	return regexp.Compile(pattern)
+}
+
+// findInBuffer returns every non-empty match of re, line by line.
+func findInBuffer(value string, re *regexp.Regexp) []editorMatch {
+	var matches []editorMatch
+	for row, line := range strings.Split(value, "\n") {
+		for _, loc := range re.FindAllStringIndex(line, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			start := utf8.RuneCountInString(line[:loc[0]])
+			end := start + utf8.RuneCountInString(line[loc[0]:loc[1]])
+			matches = append(matches, editorMatch{row, start, end})
+			if len(matches) == maxFindMatches {
+				return matches
+			}
+		}
+	}
+	return matches
+}
+
+/*
+	NOTE:
+
+replaceInLine replaces the non-empty matches of re in line: all of them
+when at is negative, otherwise only the one starting at rune column at.
+In regex mode $1-style references in repl are expanded. It returns the
+new line and how many matches were replaced.
+*/
+func replaceInLine(line string, re *regexp.Regexp, repl string, regex bool, at int) (string, int) {
+	var b strings.Builder
+	last, n := 0, 0
+	for _, sub := range re.FindAllStringSubmatchIndex(line, -1) {
+		if sub[0] == sub[1] {
+			continue
+		}
+		if at >= 0 && utf8.RuneCountInString(line[:sub[0]]) != at {
+			continue
+		}
+		b.WriteString(line[last:sub[0]])
+		if regex {
+			b.Write(re.ExpandString(nil, repl, line, sub))
+		} else {
+			b.WriteString(repl)
+		}
+		last = sub[1]
+		n++
+	}
+	b.WriteString(line[last:])
+	return b.String(), n
+}
+
+// openFind shows the find bar, with the replace field focused when replace is true.
+func (m model) openFind(replace bool) (model, tea.Cmd) {
+	m.findMode = true
+	m.findOriginRow, m.findOriginCol = m.editorCursor()
+	m.findInput.Focus()
+	m.replaceInput.Blur()
+	if replace {
+		m.findInput.Blur()
+		m.replaceInput.Focus()
+	}
+	m.runFind(true)
+	return m, nil
+}
+
+func (m *model) closeFind() {
+	m.findMode = false
+	m.findMatches = nil
+	m.findErr = ""
+	m.findInput.Blur()
+	m.replaceInput.Blur()
+}
+
+// runFind recomputes the matches. With jump set it moves to the first match after where the search began.
+func (m *model) runFind(jump bool) {
+	m.findMatches = nil
+	m.findErr = ""
+	m.findIdx = 0
+	query := m.findInput.Value()
+	if query == "" {
+		return
+	}
+	re, err := findRegexp(query, m.findRegex, m.findCase)
+	if err != nil {
+		m.findErr = "invalid regex"
+		return
+	}
+	m.findMatches = findInBuffer(m.editorContent.Value(), re)
+	if len(m.findMatches) == 0 {
+		return
+	}
+	for i, match := range m.findMatches {
+		if match.row > m.findOriginRow || (match.row == m.findOriginRow && match.start >= m.findOriginCol) {
+			m.findIdx = i
+			break
+		}
+	}
+	if jump {
+		m.gotoMatch()
+	}
+}
+
+func (m *model) gotoMatch() {
+	if len(m.findMatches) == 0 {
+		return
+	}
+	match := m.findMatches[m.findIdx]
+	moveEditorCursor(&m.editorContent, match.row, match.start)
+}
+
+// stepMatch moves to the next (dir > 0) or previous match, wrapping around.
+func (m *model) stepMatch(dir int) {
+	if len(m.findMatches) == 0 {
+		return
+	}
+	m.findIdx = (m.findIdx + dir + len(m.findMatches)) % len(m.findMatches)
+	m.gotoMatch()
+}
+
+// replaceMatches replaces the current match, or every match when all is set.
+func (m *model) replaceMatches(all bool) {
+	if len(m.findMatches) == 0 {
+		m.editorMsg = "No matches"
+		return
+	}
+	re, err := findRegexp(m.findInput.Value(), m.findRegex, m.findCase)
+	if err != nil {
+		return
+	}
+
+	before := currentEditState(m.editorContent)
+	lines := strings.Split(before.value, "\n")
+	var oldLine string
+	repl := m.replaceInput.Value()
+	count := 0
+	current := m.findMatches[m.findIdx]
+	if all {
+		for i, line := range lines {
+			var n int
+			lines[i], n = replaceInLine(line, re, repl, m.findRegex, -1)
+			count += n
+		}
+	} else {
+		oldLine = lines[current.row]
+		lines[current.row], count = replaceInLine(lines[current.row], re, repl, m.findRegex, current.start)
+	}
+	if count == 0 {
+		return
+	}
+
+	m.editorUndo.record(before, editOther, true, time.Now())
+	m.editorContent.SetValue(strings.Join(lines, "\n"))
+
+	if all {
+		moveEditorCursor(&m.editorContent, before.row, before.col)
+		m.findOriginRow, m.findOriginCol = before.row, before.col
+		m.editorMsg = fmt.Sprintf("Replaced %d", count)
+	} else {
+		// Carry on from just after the replacement.
+		grown := utf8.RuneCountInString(lines[current.row]) - utf8.RuneCountInString(oldLine)
+		m.findOriginRow = current.row
+		m.findOriginCol = current.end + grown
+		moveEditorCursor(&m.editorContent, m.findOriginRow, m.findOriginCol)
+		m.editorMsg = "Replaced 1"
+	}
+	m.runFind(!all)
+}
+
+// updateFind handles keys while the find bar is open.
+func (m model) updateFind(msg tea.KeyMsg) (model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.closeFind()
+		return m, nil
+	case "tab", "shift+tab":
+		if m.findInput.Focused() {
+			m.findInput.Blur()
+			m.replaceInput.Focus()
+		} else {
+			m.replaceInput.Blur()
+			m.findInput.Focus()
+		}
+		return m, nil
+	case "alt+c":
+		m.findCase = !m.findCase
+		m.runFind(true)
+		return m, nil
+	case "alt+r":
+		m.findRegex = !m.findRegex
+		m.runFind(true)
+		return m, nil
+	case "alt+a":
+		m.replaceMatches(true)
+		return m, nil
+	case "down":
+		m.stepMatch(1)
+		return m, nil
+	case "up":
+		m.stepMatch(-1)
+		return m, nil
+	case "enter":
+		if m.replaceInput.Focused() {
+			m.replaceMatches(false)
+		} else {
+			m.stepMatch(1)
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	if m.replaceInput.Focused() {
+		m.replaceInput, cmd = m.replaceInput.Update(msg)
+		return m, cmd
+	}
+	query := m.findInput.Value()
+	m.findInput, cmd = m.findInput.Update(msg)
+	if m.findInput.Value() != query {
+		m.runFind(true)
+	}
+	return m, cmd
+}
+
+// findBarView renders the find and replace fields with the match count and active modes.
+func (m model) findBarView() string {
+	muted := lipgloss.NewStyle().Foreground(m.theme.Muted)
+	on := lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true)
+	toggle := func(label string, active bool) string {
+		if active {
+			return on.Render(label)
+		}
+		return muted.Render(label)
+	}
+
+	status := ""
+	switch {
+	case m.findErr != "":
+		status = on.Render(m.findErr)
+	case m.findInput.Value() == "":
+	case len(m.findMatches) == 0:
+		status = muted.Render("no matches")
+	default:
+		status = muted.Render(fmt.Sprintf("%d of %d", m.findIdx+1, len(m.findMatches)))
+	}
+
+	find := fmt.Sprintf("  Find    %s  %s  %s  %s", m.findInput.View(),
+		toggle("Aa alt+c", m.findCase), toggle(".* alt+r", m.findRegex), status)
+	replace := fmt.Sprintf("  Replace %s  %s", m.replaceInput.View(),
+		muted.Render("enter: replace  alt+a: all  tab: switch  esc: close"))
+	return find + "\n" + replace
+}
@@ -0,0 +1,170 @@
+// NOTE: Per-note snapshot history, stored gzipped under <vault>/.history
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSnapshots is how many earlier versions are kept per note.
+const maxSnapshots = 50
+
+type snapshot struct {
+	id   string // file name without .gz, the unix nano timestamp
+	time time.Time
+}
+
+func historyRoot() string {
+	return filepath.Join(vaultDir, ".history")
+}
+
+/*
+	NOTE:
+
+Each note gets a directory named after its escaped vault-relative path,
+so work/a.md lives in .history/work%2Fa.md/ and names can never collide.
+*/
+func historyDir(path string) string {
+	rel, err := filepath.Rel(vaultDir, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	return filepath.Join(historyRoot(), url.PathEscape(filepath.ToSlash(rel)))
+}
+
+// saveSnapshot stores content as a new version of the note at path, unless it matches the newest one.
+func saveSnapshot(path string, content []byte) error {
+	snaps := listSnapshots(path)
+	if len(snaps) > 0 {
+		if latest, err := readSnapshot(path, snaps[0]); err == nil && bytes.Equal(latest, content) {
+			return nil
+		}
+	}
+
+	dir := historyDir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(content); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	id := strconv.FormatInt(time.Now().UnixNano(), 10)
+	if err := os.WriteFile(filepath.Join(dir, id+".gz"), buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+
+	// Drop the oldest versions past the limit.
+	snaps = listSnapshots(path)
+	for _, s := range snaps[min(len(snaps), maxSnapshots):] {
+		os.Remove(filepath.Join(dir, s.id+".gz"))
+	}
+	return nil
+}
+
+// snapshotBeforeWrite keeps the current on-disk version of path if newContent would change it.
+func snapshotBeforeWrite(path string, newContent []byte) error {
+	old, err := os.ReadFile(path)
+	if err != nil || len(old) == 0 || bytes.Equal(old, newContent) {
+		return nil
+	}
+	return saveSnapshot(path, old)
+}
+
+// snapshotIfEdited keeps original, read before an external edit, if the file has changed since.
+func snapshotIfEdited(path string, original []byte) {
+	if len(original) == 0 {
+		return
+	}
+	if edited, err := os.ReadFile(path); err == nil && !bytes.Equal(edited, original) {
+		saveSnapshot(path, original)
+	}
+}
+
+// listSnapshots returns the saved versions of the note at path, newest first.
+func listSnapshots(path string) []snapshot {
+	entries, err := os.ReadDir(historyDir(path))
+	if err != nil {
+		return nil
+	}
+
+	var snaps []snapshot
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".gz")
+		if !ok {
+			continue
+		}
+		nanos, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		snaps = append(snaps, snapshot{id: id, time: time.Unix(0, nanos)})
+	}
+
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].time.After(snaps[j].time)
+	})
+	return snaps
+}
+
+func readSnapshot(path string, s snapshot) ([]byte, error) {
+	f, err := os.Open(filepath.Join(historyDir(path), s.id+".gz"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// restoreSnapshot overwrites the note with an earlier version, keeping the current one in history.
+func restoreSnapshot(path string, s snapshot) error {
+	content, err := readSnapshot(path, s)
+	if err != nil {
+		return err
+	}
+	if err := snapshotBeforeWrite(path, content); err != nil {
+		return err
+	}
+	// The note keeps its mode; one that's gone comes back private if it's encrypted, like writeEncryptedNote makes it.
+	perm := os.FileMode(0o644)
+	if isEncryptedFile(path) {
+		perm = 0o600
+	}
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	return os.WriteFile(path, content, perm)
+}
+
+// moveHistory carries a note's history along when it is renamed.
+func moveHistory(oldPath, newPath string) {
+	oldDir := historyDir(oldPath)
+	if _, err := os.Stat(oldDir); err != nil {
+		return
+	}
+	newDir := historyDir(newPath)
+	if _, err := os.Stat(newDir); err == nil {
+		return
+	}
+	os.Rename(oldDir, newDir)
+}
@@ -0,0 +1,535 @@
+// NOTE: `yap import`, bringing notes over from Obsidian, Joplin and plain markdown folders
+
+package main
+
+import (
+	"archive/tar"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxSummary is how long a description taken from a note's first paragraph can get.
+const maxSummary = 100
+
+// importer writes notes into the vault and keeps the report of what happened.
+type importer struct {
+	into    string // vault directory notes are imported into
+	notes   int
+	files   int
+	skipped []string
+	// descs holds the imported descriptions by note path, saved to the metadata index in one write at the end.
+	descs map[string]string
+}
+
+func (im *importer) skip(name, reason string) {
+	im.skipped = append(im.skipped, fmt.Sprintf("%s (%s)", name, reason))
+}
+
+// target is where rel lands in the vault, or "" after reporting why it can't be written there.
+func (im *importer) target(rel string) string {
+	dst := filepath.Join(im.into, rel)
+	if _, err := os.Stat(dst); err == nil {
+		im.skip(rel, "already in the vault")
+		return ""
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		im.skip(rel, err.Error())
+		return ""
+	}
+	return dst
+}
+
+// writeNote adds a note with its description, keeping the time it was last modified.
+func (im *importer) writeNote(rel string, content []byte, desc string, modified time.Time) {
+	dst := im.target(rel)
+	if dst == "" {
+		return
+	}
+	if err := os.WriteFile(dst, content, 0o644); err != nil {
+		im.skip(rel, err.Error())
+		return
+	}
+	if desc != "" {
+		im.descs[dst] = desc
+	}
+	if !modified.IsZero() {
+		os.Chtimes(dst, modified, modified)
+	}
+	im.notes++
+}
+
+// copyFile carries an attachment over as it is.
+func (im *importer) copyFile(rel, src string, modified time.Time) {
+	dst := im.target(rel)
+	if dst == "" {
+		return
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		im.skip(rel, err.Error())
+		return
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		im.skip(rel, err.Error())
+		return
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		im.skip(rel, err.Error())
+		return
+	}
+	if !modified.IsZero() {
+		os.Chtimes(dst, modified, modified)
+	}
+	im.files++
+}
+
+/*
+	NOTE:
+
+noteSummary picks a description for an imported note: a description or
+summary field in its frontmatter, otherwise the first line of its first
+paragraph, shortened to maxSummary characters.
+*/
+func noteSummary(content string) string {
+	fm, body, ok := splitFrontmatter(content)
+	if ok {
+		for _, line := range strings.Split(fm, "\n") {
+			k, v, found := strings.Cut(strings.TrimRight(line, "\r"), ":")
+			if k = strings.TrimSpace(k); found && (k == "description" || k == "summary") {
+				if v = strings.Trim(strings.TrimSpace(v), `"'`); v != "" {
+					return v
+				}
+			}
+		}
+	}
+
+	inFence := false
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "![") {
+			continue
+		}
+		line = strings.TrimLeft(line, "-*>+ ")
+		if utf8.RuneCountInString(line) > maxSummary {
+			line = string([]rune(line)[:maxSummary-1]) + "…"
+		}
+		return line
+	}
+	return ""
+}
+
+var noteExts = map[string]bool{".md": true, ".markdown": true, ".txt": true}
+
+/*
+	NOTE:
+
+importMarkdownDir copies a folder of notes as it is, folders and all.
+Markdown and text files become notes; anything else is an attachment and
+is copied next to them, so relative links keep working. Hidden files and
+folders are left out, and for Obsidian so is its .obsidian settings folder
+(which is hidden anyway) and its .trash.
+*/
+func (im *importer) importMarkdownDir(root string) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a folder", root)
+	}
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		rel, _ := filepath.Rel(root, p)
+		if err != nil {
+			im.skip(rel, err.Error())
+			return nil
+		}
+		if p == root {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if strings.HasSuffix(d.Name(), ".textbundle") {
+				im.skip(rel, "TextBundle; export as plain markdown instead")
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			im.skip(rel, "not a regular file")
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			im.skip(rel, err.Error())
+			return nil
+		}
+		if !noteExts[strings.ToLower(filepath.Ext(p))] {
+			im.copyFile(rel, p, info.ModTime())
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			im.skip(rel, err.Error())
+			return nil
+		}
+		im.writeNote(rel, content, noteSummary(string(content)), info.ModTime())
+		return nil
+	})
+}
+
+// Joplin item types, from the type_ field of its export.
+const (
+	joplinNote     = "1"
+	joplinFolder   = "2"
+	joplinResource = "4"
+	joplinTag      = "5"
+	joplinNoteTag  = "6"
+)
+
+// joplinItem is one item of a Joplin RAW or JEX export: a title, a body and metadata fields.
+type joplinItem struct {
+	title string
+	body  string
+	meta  map[string]string
+}
+
+var joplinMetaRe = regexp.MustCompile(`^([a-z_]+): ?(.*)$`)
+
+// parseJoplinItem splits an exported item into its title, body and the metadata block at its end.
+func parseJoplinItem(content string) joplinItem {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	item := joplinItem{meta: map[string]string{}}
+	end := len(lines)
+	for end > 0 {
+		m := joplinMetaRe.FindStringSubmatch(lines[end-1])
+		if m == nil {
+			break
+		}
+		item.meta[m[1]] = m[2]
+		end--
+	}
+	lines = lines[:end]
+	if len(lines) > 0 {
+		item.title = strings.TrimSpace(lines[0])
+		lines = lines[1:]
+	}
+	item.body = strings.Trim(strings.Join(lines, "\n"), "\n")
+	return item
+}
+
+// joplinTime reads one of Joplin's timestamps, preferring the user-editable one.
+func (it joplinItem) time(field string) time.Time {
+	for _, f := range []string{"user_" + field, field} {
+		if t, err := time.Parse(time.RFC3339Nano, it.meta[f]); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// safeName makes a Joplin title usable as a file or folder name.
+func safeName(title string) string {
+	name := strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|':
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		return "Untitled"
+	}
+	return name
+}
+
+// linkPath escapes a vault-relative path for a markdown link.
+func linkPath(rel string) string {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i, p := range parts {
+		if p != ".." {
+			parts[i] = url.PathEscape(p)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// extractJEX unpacks a Joplin JEX archive (a plain tar) into a temporary folder.
+func extractJEX(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	dir, err := os.MkdirTemp("", "yappad-jex-")
+	if err != nil {
+		return "", err
+	}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return dir, nil
+		}
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("%s is not a JEX archive: %w", path, err)
+		}
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if hdr.Typeflag != tar.TypeReg || filepath.IsAbs(name) || strings.HasPrefix(name, "..") {
+			continue
+		}
+		dst := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		out, err := os.Create(dst)
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		_, err = io.Copy(out, tr)
+		out.Close()
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+}
+
+var joplinLinkRe = regexp.MustCompile(`\(:/([0-9a-f]{32})(#[^)]*)?\)`)
+
+/*
+	NOTE:
+
+importJoplin reads a Joplin RAW export folder, or a JEX archive, which is
+the same thing in a tar. Items are named by id, so the folder tree is
+rebuilt from the notebooks' titles and parents, and notes are named after
+their titles. Resources go into _resources, and the :/id links Joplin
+uses for resources and other notes become relative links. Tags are put in
+the note's frontmatter.
+*/
+func (im *importer) importJoplin(src string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		dir, err := extractJEX(src)
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		src = dir
+	}
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	items := map[string]joplinItem{}
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".md" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(src, e.Name()))
+		if err != nil {
+			im.skip(e.Name(), err.Error())
+			continue
+		}
+		it := parseJoplinItem(string(data))
+		id := it.meta["id"]
+		if id == "" {
+			im.skip(e.Name(), "not a Joplin item")
+			continue
+		}
+		items[id] = it
+		ids = append(ids, id)
+	}
+	if len(items) == 0 {
+		return fmt.Errorf("no Joplin items found in %s", src)
+	}
+	sort.Strings(ids)
+
+	// Notebook paths, built from each folder's chain of parents.
+	var folderPath func(id string, depth int) string
+	folderPath = func(id string, depth int) string {
+		it, ok := items[id]
+		if !ok || it.meta["type_"] != joplinFolder || depth > 100 {
+			return ""
+		}
+		return filepath.Join(folderPath(it.meta["parent_id"], depth+1), safeName(it.title))
+	}
+
+	tags := map[string][]string{}
+	for _, id := range ids {
+		it := items[id]
+		if it.meta["type_"] == joplinNoteTag {
+			if tag, ok := items[it.meta["tag_id"]]; ok {
+				name := strings.ReplaceAll(strings.TrimSpace(tag.title), " ", "-")
+				tags[it.meta["note_id"]] = append(tags[it.meta["note_id"]], name)
+			}
+		}
+	}
+
+	// Work out every note's and resource's path first, so links between them can be rewritten.
+	paths := map[string]string{}
+	taken := map[string]bool{}
+	unique := func(rel string) string {
+		ext := filepath.Ext(rel)
+		base := strings.TrimSuffix(rel, ext)
+		for n := 2; taken[strings.ToLower(rel)]; n++ {
+			rel = fmt.Sprintf("%s (%d)%s", base, n, ext)
+		}
+		taken[strings.ToLower(rel)] = true
+		return rel
+	}
+	for _, id := range ids {
+		it := items[id]
+		switch it.meta["type_"] {
+		case joplinNote:
+			if it.meta["encryption_applied"] == "1" {
+				im.skip(id, "encrypted in Joplin")
+				continue
+			}
+			paths[id] = unique(filepath.Join(folderPath(it.meta["parent_id"], 0), safeName(it.title)+".md"))
+		case joplinResource:
+			name := safeName(it.title)
+			if ext := it.meta["file_extension"]; ext != "" && !strings.HasSuffix(strings.ToLower(name), "."+strings.ToLower(ext)) {
+				name += "." + ext
+			}
+			paths[id] = unique(filepath.Join("_resources", name))
+		}
+	}
+
+	for _, id := range ids {
+		it := items[id]
+		switch it.meta["type_"] {
+		case joplinResource:
+			rel, ok := paths[id]
+			if !ok {
+				continue
+			}
+			file := filepath.Join(src, "resources", id)
+			if ext := it.meta["file_extension"]; ext != "" {
+				file += "." + ext
+			}
+			im.copyFile(rel, file, it.time("updated_time"))
+
+		case joplinNote:
+			rel, ok := paths[id]
+			if !ok {
+				continue
+			}
+			body := joplinLinkRe.ReplaceAllStringFunc(it.body, func(m string) string {
+				sub := joplinLinkRe.FindStringSubmatch(m)
+				target, ok := paths[sub[1]]
+				if !ok {
+					return m
+				}
+				link, err := filepath.Rel(filepath.Dir(rel), target)
+				if err != nil {
+					return m
+				}
+				return "(" + linkPath(link) + sub[2] + ")"
+			})
+			if t := tags[id]; len(t) > 0 {
+				body = "---\ntags: [" + strings.Join(t, ", ") + "]\n---\n\n" + body
+			}
+			desc := noteSummary(body)
+			if safeName(it.title) != it.title {
+				// The file name couldn't hold the title as written, so keep it in the description.
+				desc = it.title
+			}
+			im.writeNote(rel, []byte(body+"\n"), desc, it.time("updated_time"))
+
+		case joplinFolder, joplinTag, joplinNoteTag:
+		default:
+			im.skip(it.title, "unsupported Joplin item type "+it.meta["type_"])
+		}
+	}
+	return nil
+}
+
+func cmdImport(cfg Config, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fromFlag := fs.String("from", "", "")
+	intoFlag := fs.String("into", "", "")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 || *fromFlag == "" {
+		return errors.New("usage: yap import --from obsidian|joplin|markdown-dir <path> [--into folder]")
+	}
+
+	into := vaultDir
+	if *intoFlag != "" {
+		if into, err = notePath(*intoFlag); err != nil {
+			return err
+		}
+	}
+	im := &importer{into: into, descs: map[string]string{}}
+	src := positional[0]
+
+	switch *fromFlag {
+	case "obsidian", "markdown-dir", "bear":
+		err = im.importMarkdownDir(src)
+	case "joplin":
+		err = im.importJoplin(src)
+	default:
+		return fmt.Errorf("unknown source %q, use obsidian, joplin or markdown-dir", *fromFlag)
+	}
+	if err != nil {
+		return err
+	}
+	err = updateMeta(func(notes map[string]noteMeta) bool {
+		for path, desc := range im.descs {
+			n := notes[metaKey(path)]
+			n.Description = desc
+			notes[metaKey(path)] = n
+		}
+		return len(im.descs) > 0
+	})
+	if err != nil {
+		return fmt.Errorf("saving descriptions: %w", err)
+	}
+
+	fmt.Printf("Imported %d notes and %d attachments into %s\n", im.notes, im.files, into)
+	if len(im.skipped) > 0 {
+		fmt.Printf("Skipped %d:\n", len(im.skipped))
+		for _, s := range im.skipped {
+			fmt.Printf("  %s\n", s)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,15 @@
 package main
 
-import "github.com/charmbracelet/bubbles/key"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
 
 type keyMap struct {
 	New            key.Binding
@@ -8,7 +17,29 @@ type keyMap struct {
 	Delete         key.Binding
 	TogglePreview  key.Binding
 	CycleSort      key.Binding
+	Search         key.Binding
+	FollowLink     key.Binding
+	Tags           key.Binding
+	Trash          key.Binding
+	History        key.Binding
+	ToggleTree     key.Binding
+	Daily          key.Binding
+	PrevDay        key.Binding
+	NextDay        key.Binding
+	UndoDelete     key.Binding
+	Encrypt        key.Binding
+	Pin            key.Binding
+	PinnedOnly     key.Binding
 	ToggleHelpMenu key.Binding
+
+	// Inbuilt editor
+	EditorSave    key.Binding
+	EditorClose   key.Binding
+	EditorUndo    key.Binding
+	EditorRedo    key.Binding
+	EditorPreview key.Binding
+	EditorFind    key.Binding
+	EditorReplace key.Binding
 }
 
 func newListKeyMap() *keyMap {
@@ -18,6 +49,174 @@ func newListKeyMap() *keyMap {
 		Delete:         key.NewBinding(key.WithKeys("ctrl+d"), key.WithHelp("ctrl+d", "delete")),
 		TogglePreview:  key.NewBinding(key.WithKeys("ctrl+p"), key.WithHelp("ctrl+p", "preview")),
 		CycleSort:      key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("ctrl+s", "sort")),
+		Search:         key.NewBinding(key.WithKeys("ctrl+f"), key.WithHelp("ctrl+f", "search contents")),
+		FollowLink:     key.NewBinding(key.WithKeys("ctrl+l"), key.WithHelp("ctrl+l", "follow link")),
+		Tags:           key.NewBinding(key.WithKeys("ctrl+t"), key.WithHelp("ctrl+t", "tags")),
+		Trash:          key.NewBinding(key.WithKeys("ctrl+b"), key.WithHelp("ctrl+b", "trash")),
+		UndoDelete:     key.NewBinding(key.WithKeys("ctrl+z"), key.WithHelp("ctrl+z", "undo delete")),
+		History:        key.NewBinding(key.WithKeys("ctrl+o"), key.WithHelp("ctrl+o", "history")),
+		ToggleTree:     key.NewBinding(key.WithKeys("ctrl+e"), key.WithHelp("ctrl+e", "tree view")),
+		Daily:          key.NewBinding(key.WithKeys("ctrl+a"), key.WithHelp("ctrl+a", "today")),
+		PrevDay:        key.NewBinding(key.WithKeys("["), key.WithHelp("[", "previous day")),
+		NextDay:        key.NewBinding(key.WithKeys("]"), key.WithHelp("]", "next day")),
+		Encrypt:        key.NewBinding(key.WithKeys("ctrl+x"), key.WithHelp("ctrl+x", "encrypt/decrypt")),
+		Pin:            key.NewBinding(key.WithKeys("ctrl+g"), key.WithHelp("ctrl+g", "pin")),
+		PinnedOnly:     key.NewBinding(key.WithKeys("alt+g"), key.WithHelp("alt+g", "pinned only")),
 		ToggleHelpMenu: key.NewBinding(key.WithKeys("ctrl+h"), key.WithHelp("ctrl+h", "Toggle Help")),
+
+		EditorSave:    key.NewBinding(key.WithKeys("ctrl+s"), key.WithHelp("ctrl+s", "save")),
+		EditorClose:   key.NewBinding(key.WithKeys("ctrl+q"), key.WithHelp("ctrl+q", "close")),
+		EditorUndo:    key.NewBinding(key.WithKeys("ctrl+z"), key.WithHelp("ctrl+z", "undo")),
+		EditorRedo:    key.NewBinding(key.WithKeys("ctrl+y"), key.WithHelp("ctrl+y", "redo")),
+		EditorPreview: key.NewBinding(key.WithKeys("ctrl+p"), key.WithHelp("ctrl+p", "preview")),
+		EditorFind:    key.NewBinding(key.WithKeys("ctrl+f"), key.WithHelp("ctrl+f", "find")),
+		EditorReplace: key.NewBinding(key.WithKeys("ctrl+r"), key.WithHelp("ctrl+r", "replace")),
+	}
+}
+
+// keyAction is a binding as it's named in the [keys] config table.
+type keyAction struct {
+	name    string
+	binding *key.Binding
+	editor  bool // only active in the inbuilt editor
+}
+
+func (k *keyMap) actions() []keyAction {
+	return []keyAction{
+		{"new", &k.New, false},
+		{"rename", &k.Rename, false},
+		{"delete", &k.Delete, false},
+		{"toggle_preview", &k.TogglePreview, false},
+		{"cycle_sort", &k.CycleSort, false},
+		{"search", &k.Search, false},
+		{"follow_link", &k.FollowLink, false},
+		{"tags", &k.Tags, false},
+		{"trash", &k.Trash, false},
+		{"history", &k.History, false},
+		{"toggle_tree", &k.ToggleTree, false},
+		{"daily", &k.Daily, false},
+		{"prev_day", &k.PrevDay, false},
+		{"next_day", &k.NextDay, false},
+		{"undo_delete", &k.UndoDelete, false},
+		{"encrypt", &k.Encrypt, false},
+		{"pin", &k.Pin, false},
+		{"pinned_only", &k.PinnedOnly, false},
+		{"toggle_help", &k.ToggleHelpMenu, false},
+
+		{"editor_save", &k.EditorSave, true},
+		{"editor_close", &k.EditorClose, true},
+		{"editor_undo", &k.EditorUndo, true},
+		{"editor_redo", &k.EditorRedo, true},
+		{"editor_preview", &k.EditorPreview, true},
+		{"editor_find", &k.EditorFind, true},
+		{"editor_replace", &k.EditorReplace, true},
+	}
+}
+
+// keyList is one action's keys in the config: a single string or a list of them.
+type keyList []string
+
+func (l *keyList) UnmarshalTOML(v any) error {
+	switch v := v.(type) {
+	case string:
+		*l = keyList{v}
+	case []any:
+		for _, k := range v {
+			s, ok := k.(string)
+			if !ok {
+				return fmt.Errorf("keys must be strings, got %v", k)
+			}
+			*l = append(*l, s)
+		}
+	default:
+		return fmt.Errorf("keys must be a string or a list of strings, got %v", v)
+	}
+	return nil
+}
+
+// reservedListKeys are the list's own bindings, which the [keys] table can't take over.
+func reservedListKeys() map[string]string {
+	km := list.DefaultKeyMap()
+	reserved := map[string]string{"enter": "open"}
+	for _, b := range []key.Binding{
+		km.CursorUp, km.CursorDown, km.PrevPage, km.NextPage, km.GoToStart, km.GoToEnd,
+		km.Filter, km.ClearFilter, km.ShowFullHelp, km.Quit, km.ForceQuit,
+	} {
+		for _, k := range b.Keys() {
+			reserved[k] = b.Help().Desc
+		}
+	}
+	return reserved
+}
+
+/*
+	NOTE:
+
+newKeyMap applies the [keys] config table over the defaults. Each action
+takes one key or a list of them, and an empty list unbinds it. Help text
+follows the keys, so the help view always shows what's actually bound.
+Two actions sharing a key in the list or in the editor is an error, as one
+of them could never fire.
+*/
+func newKeyMap(overrides map[string]keyList) (*keyMap, error) {
+	k := newListKeyMap()
+	actions := k.actions()
+	byName := make(map[string]keyAction, len(actions))
+	for _, a := range actions {
+		byName[a.name] = a
+	}
+
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		a, ok := byName[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("keys: unknown action %q", name))
+			continue
+		}
+		var keys []string
+		for _, s := range overrides[name] {
+			if s = strings.TrimSpace(s); s != "" {
+				keys = append(keys, s)
+			}
+		}
+		a.binding.SetKeys(keys...)
+		a.binding.SetHelp(strings.Join(keys, "/"), a.binding.Help().Desc)
+		a.binding.SetEnabled(len(keys) > 0)
+	}
+
+	reserved := reservedListKeys()
+	bound := map[bool]map[string]string{false: {}, true: {}}
+	for _, a := range actions {
+		for _, s := range a.binding.Keys() {
+			if other, ok := bound[a.editor][s]; ok {
+				errs = append(errs, fmt.Errorf("keys: %q is bound to both %s and %s", s, other, a.name))
+				continue
+			}
+			if desc, ok := reserved[s]; ok && !a.editor {
+				errs = append(errs, fmt.Errorf("keys: %q for %s is already the list's %q key", s, a.name, desc))
+				continue
+			}
+			bound[a.editor][s] = a.name
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("bad key bindings in %s:\n%w", configPath(), err)
+	}
+	return k, nil
+}
+
+// editorAction reports whether msg is bound to one of the editor actions.
+func (k *keyMap) editorAction(msg tea.KeyMsg) bool {
+	for _, a := range k.actions() {
+		if a.editor && key.Matches(msg, *a.binding) {
+			return true
+		}
 	}
+	return false
 }
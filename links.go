@@ -0,0 +1,131 @@
+// NOTE: [[wiki links]], relative markdown links and backlinks between notes
+
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+var (
+	wikiLinkRe     = regexp.MustCompile(`\[\[([^\[\]]+)\]\]`)
+	markdownLinkRe = regexp.MustCompile(`\[[^\[\]]*\]\(([^()\s]+)(?:\s+"[^"]*")?\)`)
+)
+
+// rawLink is a link as written in a note, before it is resolved against the vault.
+type rawLink struct {
+	target string
+	wiki   bool
+}
+
+func parseLinks(content string) []rawLink {
+	var links []rawLink
+	for _, m := range wikiLinkRe.FindAllStringSubmatch(content, -1) {
+		target := m[1]
+		// [[note|alias]] and [[note#heading]]
+		if i := strings.IndexAny(target, "|#"); i >= 0 {
+			target = target[:i]
+		}
+		target = strings.TrimSpace(target)
+		if target != "" {
+			links = append(links, rawLink{target: target, wiki: true})
+		}
+	}
+	for _, m := range markdownLinkRe.FindAllStringSubmatch(content, -1) {
+		target := m[1]
+		if strings.Contains(target, "://") || strings.HasPrefix(target, "mailto:") || strings.HasPrefix(target, "#") {
+			continue
+		}
+		if i := strings.Index(target, "#"); i >= 0 {
+			target = target[:i]
+		}
+		if unescaped, err := url.PathUnescape(target); err == nil {
+			target = unescaped
+		}
+		if target != "" {
+			links = append(links, rawLink{target: target})
+		}
+	}
+	return links
+}
+
+// resolveLink maps a link written in the note `from` to the title of an existing note, or "".
+func resolveLink(l rawLink, from string, titles map[string]bool) string {
+	if !l.wiki {
+		target := filepath.Clean(filepath.Join(filepath.Dir(from), filepath.FromSlash(l.target)))
+		if strings.HasPrefix(l.target, "/") {
+			target = filepath.Clean(strings.TrimPrefix(filepath.FromSlash(l.target), string(filepath.Separator)))
+		}
+		if titles[target] {
+			return target
+		}
+		if titles[target+".md"] {
+			return target + ".md"
+		}
+		return ""
+	}
+
+	name := filepath.FromSlash(l.target)
+	for _, candidate := range []string{name, name + ".md"} {
+		if titles[candidate] {
+			return candidate
+		}
+	}
+
+	// Fall back to matching the file name anywhere in the vault, ignoring case and extension.
+	want := strings.ToLower(name)
+	var found []string
+	for t := range titles {
+		base := strings.ToLower(filepath.Base(t))
+		if base == want || strings.TrimSuffix(base, filepath.Ext(base)) == want {
+			found = append(found, t)
+		}
+	}
+	if len(found) == 0 {
+		return ""
+	}
+	sort.Strings(found)
+	return found[0]
+}
+
+// noteLinks returns the notes that title links to and the notes that link to title.
+func noteLinks(title string) (to, from []string) {
+	items := listFiles(sortNameAsc)
+	titles := make(map[string]bool, len(items))
+	for _, it := range items {
+		titles[it.(item).title] = true
+	}
+
+	seenTo := map[string]bool{}
+	seenFrom := map[string]bool{}
+	for _, it := range items {
+		i := it.(item)
+		for _, l := range scanNote(filepath.Join(vaultDir, i.title), i.modTime).links {
+			target := resolveLink(l, i.title, titles)
+			if target == "" || target == i.title {
+				continue
+			}
+			if i.title == title && !seenTo[target] {
+				seenTo[target] = true
+				to = append(to, target)
+			}
+			if target == title && !seenFrom[i.title] {
+				seenFrom[i.title] = true
+				from = append(from, i.title)
+			}
+		}
+	}
+	return to, from
+}
+
+func loadLinks(title string) tea.Cmd {
+	return func() tea.Msg {
+		to, from := noteLinks(title)
+		return linksLoadedMsg{title: title, to: to, from: from}
+	}
+}
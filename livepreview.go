@@ -0,0 +1,100 @@
+// NOTE: Live markdown preview next to the inbuilt editor
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/wordwrap"
+)
+
+// previewDebounce is how long typing has to pause before the preview re-renders.
+const previewDebounce = 150 * time.Millisecond
+
+// editorPreviewTickMsg fires once typing pauses; stale ticks carry an old seq.
+type editorPreviewTickMsg struct {
+	seq int
+}
+
+// editorPreviewMsg carries the rendered buffer for render request seq.
+type editorPreviewMsg struct {
+	seq     int
+	content string
+}
+
+func isMarkdownFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(plainName(path)))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// previewWidth is what's left of the screen beside the editor, less the divider.
+func (m model) previewWidth() int {
+	return max(1, m.width-m.editorWidth()-3)
+}
+
+// schedulePreview asks for a re-render once typing has paused for previewDebounce.
+func (m *model) schedulePreview() tea.Cmd {
+	m.previewSeq++
+	seq := m.previewSeq
+	return tea.Tick(previewDebounce, func(time.Time) tea.Msg {
+		return editorPreviewTickMsg{seq: seq}
+	})
+}
+
+// renderEditorPreview renders the unsaved buffer off the UI goroutine.
+func (m model) renderEditorPreview() tea.Cmd {
+	seq, content, width := m.previewSeq, m.editorContent.Value(), m.previewWidth()
+	return func() tea.Msg {
+		return editorPreviewMsg{seq: seq, content: wordwrap.String(renderMarkdown(content), width)}
+	}
+}
+
+// toggleEditorPreview opens or closes the preview beside a markdown note.
+func (m model) toggleEditorPreview() (model, tea.Cmd) {
+	if !m.editorPreview && !isMarkdownFile(m.editorFile) {
+		m.editorMsg = "Live preview is only for markdown notes"
+		return m, nil
+	}
+	m.editorPreview = !m.editorPreview
+	if !m.editorPreview {
+		return m, nil
+	}
+	m.resizeEditorPreview()
+	m.previewSeq++
+	return m, m.renderEditorPreview()
+}
+
+func (m *model) resizeEditorPreview() {
+	m.previewPane.Width = m.previewWidth()
+	m.previewPane.Height = m.editorHeight()
+}
+
+/*
+	NOTE:
+
+syncPreviewScroll keeps the preview at the same relative position as the
+editor. Rendered markdown doesn't map line for line onto the source, so
+this is proportional rather than exact.
+*/
+func (m *model) syncPreviewScroll() {
+	lines := m.editorContent.LineCount()
+	if !m.editorPreview || lines <= 1 {
+		m.previewPane.GotoTop()
+		return
+	}
+	total := m.previewPane.TotalLineCount()
+	m.previewPane.SetYOffset(m.editorTop * total / lines)
+}
+
+func (m model) editorPreviewView() string {
+	return lipgloss.NewStyle().
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderLeft(true).
+		BorderForeground(m.theme.Border).
+		PaddingLeft(1).
+		Render(m.previewPane.View())
+}
@@ -0,0 +1,73 @@
+// NOTE: Advisory per-note locks, so `yap add` doesn't write under an open editor
+
+package main
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+var errNoteLocked = errors.New("note is open in a YapPad editor")
+
+/*
+	NOTE:
+
+Locks are flock(2) locks on files under <vault>/.locks, one per note and
+one for the metadata index. The kernel drops them when the holder exits,
+so a crashed TUI can never leave a note locked.
+*/
+type noteLock struct {
+	f *os.File
+}
+
+func lockFilePath(path string) string {
+	rel, err := filepath.Rel(vaultDir, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	return filepath.Join(vaultDir, ".locks", url.PathEscape(filepath.ToSlash(rel))+".lock")
+}
+
+// tryLockNote takes the lock for the note at path, or returns errNoteLocked if someone holds it.
+func tryLockNote(path string) (*noteLock, error) {
+	lp := lockFilePath(path)
+	if err := os.MkdirAll(filepath.Dir(lp), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(lp, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errNoteLocked
+		}
+		return nil, err
+	}
+	return &noteLock{f: f}, nil
+}
+
+// lockNote waits up to timeout for the lock on the note at path.
+func lockNote(path string, timeout time.Duration) (*noteLock, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		l, err := tryLockNote(path)
+		if err != errNoteLocked || time.Now().After(deadline) {
+			return l, err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func (l *noteLock) unlock() {
+	if l == nil {
+		return
+	}
+	syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+	l.f.Close()
+}
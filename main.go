@@ -8,6 +8,7 @@ and launches the Bubble Tea program.
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -23,14 +24,6 @@ func main() {
 		return
 	}
 
-	cfgFile := configPath()
-	var cfg Config
-	if _, err := os.Stat(cfgFile); os.IsNotExist(err) {
-		cfg = runSetup()
-	} else {
-		cfg = loadConfig()
-	}
-
 	// Parse flags — override config values if explicitly provided
 	themeFlag := flag.String("theme", "", "")
 	editorFlag := flag.String("editor", "", "")
@@ -38,11 +31,33 @@ func main() {
 		fmt.Fprintf(os.Stderr, `YapPad %s — a terminal note-taking app
 
 Usage:
-  yap [flags] [.]
+  yap [flags] [.] [command]
 
   yap          open your configured vault
   yap .        open current directory as vault (session only)
 
+Commands:
+  today [day]                  open or create the daily note
+                               (day: yesterday, tomorrow, +N, -N, YYYY-MM-DD)
+  ls [--sort mode] [--json]    list notes (sort: modified, modified-asc,
+                               created, created-asc, name, name-desc)
+  new <name> [--desc text] [--template name]
+                               create a note and print its path
+  cat <note>...                print notes
+  rm [--purge] <note>...       move notes to the trash (--purge deletes)
+  mv <note> <new name>         rename or move a note with its description
+  search <query>               print matching lines as file:line: text
+  add [--new] [--to note] [text]
+                               append text (or stdin) to the inbox note
+  export html [path...] [--out dir] [--sort mode]
+                               write notes as a static site (default ./site)
+  backup [--out file]          archive the whole vault as .tar.gz
+  restore <archive> [--into dir] [--dry-run] [--force]
+                               restore a backup, showing conflicts first
+  import --from <source> <path> [--into folder]
+                               copy notes in from obsidian, joplin
+                               (RAW folder or .jex) or markdown-dir
+
 Flags:
   --theme <name>    override config theme for this session
   --editor <name>   override config editor for this session
@@ -52,6 +67,7 @@ Flags:
 Themes:
   default, gruvbox, nord, tokyonight, forest, solarized,
   dracula, dusk, tide, moss, glacier, plum
+  or your own [themes.<name>] tables in the config
 
 Editors:
   inbuilt, nano, nvim, vim, hx
@@ -62,16 +78,43 @@ Config:
 Keybindings:
   n          new file
   r          rename file
-  d          delete file
+  d          delete file (moves it to the trash)
+  ctrl+z     undo last delete
+  ctrl+b     browse trash
+  ctrl+o     note history
+  ctrl+e     toggle folder tree
+  ctrl+a     open today's daily note
+  [ / ]      previous / next daily note
+  ctrl+x     encrypt / decrypt note
+  ctrl+g     pin / unpin note
+  alt+g      show pinned notes only
   enter      open in editor
   ctrl+p     toggle preview
   ctrl+s     cycle sort
   /          filter
+  ctrl+f     search note contents
+  ctrl+l     jump to a linked note
+  ctrl+t     filter by tags
   ?          toggle help
   q          quit
+
+  Change these in the [keys] table of the config.
 `, Version, configPath())
 	}
 	flag.Parse()
+	args := flag.Args()
+	if len(args) > 0 && args[0] == "." {
+		args = args[1:]
+	}
+
+	// First-run setup is interactive, so subcommands run on defaults instead.
+	cfgFile := configPath()
+	var cfg Config
+	if _, err := os.Stat(cfgFile); os.IsNotExist(err) && len(args) == 0 {
+		cfg = runSetup()
+	} else {
+		cfg = loadConfig()
+	}
 
 	if *themeFlag != "" {
 		cfg.Theme = *themeFlag
@@ -88,15 +131,53 @@ Keybindings:
 		}
 	}
 
+	if err := loadThemes(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := newKeyMap(cfg.Keys); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+
 	vaultDir = cfg.Vault
+	if err := migrateMetadesc(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	purgeOldTrash(cfg.TrashDays)
+
+	var err error
+	if len(args) > 0 {
+		cmd, ok := commands[args[0]]
+		if !ok {
+			err = fmt.Errorf("unknown command %q, see yap --help", args[0])
+		} else {
+			err = cmd(cfg, args[1:])
+		}
+	} else {
+		err = runProgram(initialModel(cfg))
+		if err == nil && cfg.BackupKeep > 0 {
+			if err = autoBackup(cfg.BackupDir, cfg.BackupKeep); err != nil {
+				err = fmt.Errorf("automatic backup: %w", err)
+			}
+		}
+	}
+	if errors.Is(err, errNoMatches) {
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
 
+func runProgram(m model) error {
 	p := tea.NewProgram(
-		initialModel(cfg.Editor, cfg.Theme),
+		m,
 		tea.WithAltScreen(),
 		tea.WithMouseCellMotion(),
 	)
-	if _, err := p.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
-	}
+	_, err := p.Run()
+	return err
 }
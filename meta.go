@@ -0,0 +1,333 @@
+// NOTE: The vault metadata index, one JSON file holding descriptions and pins for every note
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metaIndexName is the index file, at the root of the vault.
+const metaIndexName = ".yappad-meta.json"
+
+// metaLockTimeout is how long a change waits for another YapPad process to finish writing the index.
+const metaLockTimeout = 5 * time.Second
+
+// metaIndexVersion is bumped whenever the layout of the index changes in a way older versions can't read.
+const metaIndexVersion = 1
+
+// noteMeta is everything YapPad keeps about a note outside the note itself.
+type noteMeta struct {
+	Description string `json:"description,omitempty"`
+	Pinned      bool   `json:"pinned,omitempty"`
+}
+
+func (n noteMeta) empty() bool {
+	return n == noteMeta{}
+}
+
+type metaIndex struct {
+	Version int `json:"version"`
+	// Notes is keyed by the note's path relative to the vault, always with forward slashes.
+	Notes map[string]noteMeta `json:"notes"`
+}
+
+var (
+	metaMu sync.Mutex
+	// metaCache is the index as last read, reused until the file's size or modification time changes.
+	metaCache      metaIndex
+	metaCacheStamp fileStamp
+	metaCacheVault string
+)
+
+func metaIndexPath() string {
+	return filepath.Join(vaultDir, metaIndexName)
+}
+
+// metaKey is the index key for the note at filePath.
+func metaKey(filePath string) string {
+	rel, err := filepath.Rel(vaultDir, filePath)
+	if err != nil {
+		rel = filepath.Base(filePath)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// loadMetaLocked returns the index, re-reading it only if it changed on disk. A missing index is an empty one.
+func loadMetaLocked() (metaIndex, error) {
+	path := metaIndexPath()
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return metaIndex{Version: metaIndexVersion, Notes: map[string]noteMeta{}}, nil
+	}
+	if err != nil {
+		return metaIndex{}, err
+	}
+	stamp := fileStamp{info.ModTime(), info.Size()}
+	if metaCacheVault == vaultDir && metaCacheStamp == stamp && metaCache.Notes != nil {
+		return metaCache, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return metaIndex{}, err
+	}
+	var idx metaIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return metaIndex{}, fmt.Errorf("%s is damaged: %w", path, err)
+	}
+	if idx.Version > metaIndexVersion {
+		return metaIndex{}, fmt.Errorf("%s was written by a newer YapPad", path)
+	}
+	if idx.Notes == nil {
+		idx.Notes = map[string]noteMeta{}
+	}
+	metaCache, metaCacheStamp, metaCacheVault = idx, stamp, vaultDir
+	return idx, nil
+}
+
+/*
+	NOTE:
+
+saveMetaLocked writes the index crash-safely: the new index goes to a
+temporary file in the vault, is synced to disk, and only then renamed over
+the old one. A crash at any point leaves either the old index or the new
+one, never a half-written file.
+*/
+func saveMetaLocked(idx metaIndex) error {
+	idx.Version = metaIndexVersion
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(vaultDir, ".yappad-meta-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(append(data, '\n'))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), metaIndexPath()); err != nil {
+		return err
+	}
+	// The cache is dropped rather than updated, so the next read picks up the file's new stamp.
+	metaCacheVault = ""
+	return nil
+}
+
+// noteMetaFor returns what the index holds for the note at filePath.
+func noteMetaFor(filePath string) noteMeta {
+	return allNoteMeta()[metaKey(filePath)]
+}
+
+// allNoteMeta returns the whole index by metaKey, for callers going over many notes at once. It must not be modified.
+func allNoteMeta() map[string]noteMeta {
+	metaMu.Lock()
+	defer metaMu.Unlock()
+	idx, err := loadMetaLocked()
+	if err != nil {
+		return nil
+	}
+	return idx.Notes
+}
+
+/*
+	NOTE:
+
+updateMeta applies change to a fresh copy of the index and saves it, as
+one step. change reports whether it changed anything. The mutex covers
+goroutines in this process; the flock on the index's lock file covers other
+YapPad processes, so the TUI and a `yap mv` running at the same time can't
+both read the old index and have one's save overwrite the other's.
+*/
+func updateMeta(change func(notes map[string]noteMeta) bool) error {
+	metaMu.Lock()
+	defer metaMu.Unlock()
+	l, err := lockNote(metaIndexPath(), metaLockTimeout)
+	if errors.Is(err, errNoteLocked) {
+		return fmt.Errorf("%s is still being written by another YapPad", metaIndexName)
+	}
+	if err != nil {
+		return err
+	}
+	defer l.unlock()
+
+	idx, err := loadMetaLocked()
+	if err != nil {
+		return err
+	}
+	// The cached map is shared with readers, so changes go to a copy.
+	notes := make(map[string]noteMeta, len(idx.Notes))
+	for k, v := range idx.Notes {
+		notes[k] = v
+	}
+	if !change(notes) {
+		return nil
+	}
+	for k, v := range notes {
+		if v.empty() {
+			delete(notes, k)
+		}
+	}
+	return saveMetaLocked(metaIndex{Notes: notes})
+}
+
+// NOTE: Made for adding description to an item
+func writeMetaDesc(filePath, desc string) error {
+	if desc == "" {
+		return nil
+	}
+	key := metaKey(filePath)
+	return updateMeta(func(notes map[string]noteMeta) bool {
+		n := notes[key]
+		n.Description = desc
+		notes[key] = n
+		return true
+	})
+}
+
+func readMetaDesc(filePath string) string {
+	return noteMetaFor(filePath).Description
+}
+
+// deleteMeta forgets everything about the note at filePath.
+func deleteMeta(filePath string) error {
+	key := metaKey(filePath)
+	return updateMeta(func(notes map[string]noteMeta) bool {
+		_, ok := notes[key]
+		delete(notes, key)
+		return ok
+	})
+}
+
+// moveMeta carries a note's description and pin over when it's renamed, encrypted or decrypted.
+func moveMeta(oldPath, newPath string) error {
+	oldKey, newKey := metaKey(oldPath), metaKey(newPath)
+	return updateMeta(func(notes map[string]noteMeta) bool {
+		n, ok := notes[oldKey]
+		if ok {
+			delete(notes, oldKey)
+			notes[newKey] = n
+		}
+		return ok
+	})
+}
+
+func isPinned(filePath string) bool {
+	return noteMetaFor(filePath).Pinned
+}
+
+func setPinned(filePath string, pinned bool) error {
+	key := metaKey(filePath)
+	return updateMeta(func(notes map[string]noteMeta) bool {
+		n := notes[key]
+		changed := n.Pinned != pinned
+		n.Pinned = pinned
+		notes[key] = n
+		return changed
+	})
+}
+
+/*
+	NOTE:
+
+migrateMetadesc moves the old .metadesc folder, one `<path with __>.meta`
+description file and one `.pin` file per note, into the index. As `__`
+stood for a folder separator and could also be part of a name, each old
+file is matched back to its note by encoding every note path the old way.
+Files whose note is gone are dropped. Entries already in the index win,
+and .metadesc is only removed once the index is safely written, so an
+interrupted migration simply runs again on the next start.
+*/
+func migrateMetadesc() error {
+	oldDir := filepath.Join(vaultDir, ".metadesc")
+	entries, err := os.ReadDir(oldDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	byOldKey := map[string][]string{}
+	err = filepath.WalkDir(vaultDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Name()[0] == '.' && path != vaultDir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(vaultDir, path)
+		oldKey := strings.ReplaceAll(rel, string(filepath.Separator), "__")
+		byOldKey[oldKey] = append(byOldKey[oldKey], filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("migrating .metadesc: %w", err)
+	}
+
+	found := map[string]noteMeta{}
+	for _, e := range entries {
+		name := e.Name()
+		ext := filepath.Ext(name)
+		if e.IsDir() || (ext != ".meta" && ext != ".pin") {
+			continue
+		}
+		var desc string
+		if ext == ".meta" {
+			data, err := os.ReadFile(filepath.Join(oldDir, name))
+			if err != nil {
+				return fmt.Errorf("migrating .metadesc: %w", err)
+			}
+			desc = strings.TrimSpace(string(data))
+		}
+		// A name with `__` in it could have collided with a path; the old files showed on both, and so do these.
+		for _, key := range byOldKey[strings.TrimSuffix(name, ext)] {
+			n := found[key]
+			if ext == ".meta" {
+				n.Description = desc
+			} else {
+				n.Pinned = true
+			}
+			found[key] = n
+		}
+	}
+
+	err = updateMeta(func(notes map[string]noteMeta) bool {
+		for key, n := range found {
+			if _, ok := notes[key]; !ok {
+				notes[key] = n
+			}
+		}
+		return len(found) > 0
+	})
+	if err != nil {
+		return fmt.Errorf("migrating .metadesc: %w", err)
+	}
+	return os.RemoveAll(oldDir)
+}
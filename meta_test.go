@@ -0,0 +1,221 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeVault makes a fresh vault in a temp dir holding files (vault-relative, slash separated).
+func writeVault(t *testing.T, files map[string]string) {
+	t.Helper()
+	vaultDir = t.TempDir()
+	for rel, content := range files {
+		path := filepath.Join(vaultDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readIndex(t *testing.T) map[string]noteMeta {
+	t.Helper()
+	metaMu.Lock()
+	defer metaMu.Unlock()
+	idx, err := loadMetaLocked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idx.Notes
+}
+
+func TestMigrateMetadesc(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  map[string]noteMeta
+	}{
+		{
+			name: "descriptions and pins",
+			files: map[string]string{
+				"a.md":                 "",
+				"b.md":                 "",
+				".metadesc/a.md.meta":  "first\n",
+				".metadesc/b.md.pin":   "",
+				".metadesc/b.md.meta":  "second",
+				".metadesc/README.txt": "not metadata",
+			},
+			want: map[string]noteMeta{
+				"a.md": {Description: "first"},
+				"b.md": {Description: "second", Pinned: true},
+			},
+		},
+		{
+			name: "folder separator",
+			files: map[string]string{
+				"work/plan.md":                 "",
+				".metadesc/work__plan.md.meta": "nested",
+				".metadesc/work__plan.md.pin":  "",
+			},
+			want: map[string]noteMeta{
+				"work/plan.md": {Description: "nested", Pinned: true},
+			},
+		},
+		{
+			// The old key for both notes is a__b.md, so both showed this description.
+			name: "underscores in a name",
+			files: map[string]string{
+				"a__b.md":                "",
+				"a/b.md":                 "",
+				"x__y.md":                "",
+				".metadesc/a__b.md.meta": "shared",
+				".metadesc/x__y.md.pin":  "",
+			},
+			want: map[string]noteMeta{
+				"a__b.md": {Description: "shared"},
+				"a/b.md":  {Description: "shared"},
+				"x__y.md": {Pinned: true},
+			},
+		},
+		{
+			name: "note gone",
+			files: map[string]string{
+				"kept.md":                "",
+				".metadesc/kept.md.meta": "kept",
+				".metadesc/gone.md.meta": "gone",
+				".metadesc/gone.md.pin":  "",
+			},
+			want: map[string]noteMeta{
+				"kept.md": {Description: "kept"},
+			},
+		},
+		{
+			// A run that wrote the index but died before removing .metadesc; the index wins.
+			name: "interrupted migration",
+			files: map[string]string{
+				"a.md":                 "",
+				"b.md":                 "",
+				".metadesc/a.md.meta":  "old",
+				".metadesc/b.md.meta":  "from sidecar",
+				".yappad-meta.json":    `{"version": 1, "notes": {"a.md": {"description": "new", "pinned": true}}}`,
+				".metadesc/b.md.pin":   "",
+				".metadesc/other.text": "",
+			},
+			want: map[string]noteMeta{
+				"a.md": {Description: "new", Pinned: true},
+				"b.md": {Description: "from sidecar", Pinned: true},
+			},
+		},
+		{
+			name:  "nothing to migrate",
+			files: map[string]string{"a.md": ""},
+			want:  map[string]noteMeta{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeVault(t, tt.files)
+			if err := migrateMetadesc(); err != nil {
+				t.Fatal(err)
+			}
+			if got := readIndex(t); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("index = %v, want %v", got, tt.want)
+			}
+			if _, err := os.Stat(filepath.Join(vaultDir, ".metadesc")); !os.IsNotExist(err) {
+				t.Errorf(".metadesc still there after migrating: %v", err)
+			}
+
+			// Running it again is a no-op.
+			if err := migrateMetadesc(); err != nil {
+				t.Fatal(err)
+			}
+			if got := readIndex(t); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("after a second run index = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadMeta(t *testing.T) {
+	tests := []struct {
+		name    string
+		index   string // "" for no index file
+		want    map[string]noteMeta
+		wantErr string
+	}{
+		{name: "missing", want: map[string]noteMeta{}},
+		{name: "empty notes", index: `{"version": 1}`, want: map[string]noteMeta{}},
+		{
+			name:  "entries",
+			index: `{"version": 1, "notes": {"a/b.md": {"description": "d", "pinned": true}}}`,
+			want:  map[string]noteMeta{"a/b.md": {Description: "d", Pinned: true}},
+		},
+		{name: "damaged", index: `{"version": 1, "notes": {`, wantErr: "is damaged"},
+		{name: "newer", index: `{"version": 99, "notes": {}}`, wantErr: "newer YapPad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := map[string]string{}
+			if tt.index != "" {
+				files[metaIndexName] = tt.index
+			}
+			writeVault(t, files)
+
+			metaMu.Lock()
+			idx, err := loadMetaLocked()
+			metaMu.Unlock()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(idx.Notes, tt.want) {
+				t.Errorf("notes = %v, want %v", idx.Notes, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateMeta(t *testing.T) {
+	writeVault(t, map[string]string{"a.md": "", "dir/b.md": ""})
+	a, b := filepath.Join(vaultDir, "a.md"), filepath.Join(vaultDir, "dir", "b.md")
+
+	if err := writeMetaDesc(a, "about a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := setPinned(a, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := moveMeta(a, b); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]noteMeta{"dir/b.md": {Description: "about a", Pinned: true}}
+	if got := readIndex(t); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after move index = %v, want %v", got, want)
+	}
+
+	if err := setPinned(b, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := deleteMeta(b); err != nil {
+		t.Fatal(err)
+	}
+	if got := readIndex(t); len(got) != 0 {
+		t.Fatalf("after delete index = %v, want it empty", got)
+	}
+
+	// No temporary files are left behind by the saves.
+	matches, _ := filepath.Glob(filepath.Join(vaultDir, ".yappad-meta-*.tmp"))
+	if len(matches) > 0 {
+		t.Errorf("left temporary files %v", matches)
+	}
+}
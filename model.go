@@ -9,7 +9,10 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/alecthomas/chroma/v2"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
@@ -27,6 +30,8 @@ type model struct {
 	input             textinput.Model
 	descInput         textinput.Model
 	inputStep         int
+	templates         []string
+	templateIdx       int
 	viewport          viewport.Model
 	keys              *keyMap
 	inputMode         bool
@@ -45,21 +50,106 @@ type model struct {
 	editorMode        bool
 	editorFile        string
 	editorContent     textarea.Model
+	editorLock        *noteLock
+	editorSnapshotted bool // this session's starting version is already in history
+	editorSaved       string
+	editorQuitting    bool
+	editorMsg         string
+	editorUndo        editHistory
+	editorTop         int
+	editorPreview     bool
+	previewDefault    bool
+	previewPane       viewport.Model
+	previewSeq        int
+	vimEnabled        bool
+	vimState          vimState
+	vimPending        []tea.KeyMsg
+	vimAnchor         int
+	vimRegister       string
+	vimRegLines       bool
+	vimCmdline        string
+	vimLastChange     []tea.KeyMsg
+	vimRecording      []tea.KeyMsg
+	vimReplaying      bool
+	vimInsertStart    string
+	vimUndoPushed     bool
+	editorLexer       chroma.Lexer
+	editorSyntax      [][]lipgloss.Color
+	syntaxFor         string
+	syntaxSeq         int
+	findMode          bool
+	findInput         textinput.Model
+	replaceInput      textinput.Model
+	findMatches       []editorMatch
+	findIdx           int
+	findCase          bool
+	findRegex         bool
+	findErr           string
+	findOriginRow     int
+	findOriginCol     int
+	editorSession     int
+	autosave          time.Duration
 	spinner           spinner.Model
 	loadingFile       bool
+	searchMode        bool
+	searchInput       textinput.Model
+	searchResults     []searchMatch
+	searchIdx         int
+	linksTitle        string
+	linksTo           []string
+	linksFrom         []string
+	linkPicking       bool
+	linkIdx           int
+	activeTags        []string
+	pinnedOnly        bool
+	tagPicking        bool
+	tagOptions        []tagCount
+	tagSelected       map[string]bool
+	tagIdx            int
+	trashMode         bool
+	trashEntries      []trashEntry
+	trashIdx          int
+	trashPurging      bool
+	lastTrashed       *trashEntry
+	historyMode       bool
+	historyFile       string
+	historySnaps      []snapshot
+	historyIdx        int
+	treeView          bool
+	expanded          map[string]bool
+	dailyPath         string
+	dailyTemplate     string
+	passMode          bool
+	passInput         textinput.Model
+	passAction        passAction
+	passPath          string
+	passFirst         string
+	passDeclined      bool
+	vaultEvents       chan []string
 	theme             Theme
 }
 
-func (m model) Init() tea.Cmd { return nil }
+func (m model) Init() tea.Cmd {
+	watchVault(m.vaultEvents)
+	return waitForVaultChange(m.vaultEvents)
+}
 
-func initialModel(editor string, themeName string) model {
-	listKeys := newListKeyMap()
+func initialModel(cfg Config) model {
+	// main has already reported bad bindings, so fall back to the defaults here.
+	listKeys, err := newKeyMap(cfg.Keys)
+	if err != nil {
+		listKeys = newListKeyMap()
+	}
 
 	if err := os.MkdirAll(vaultDir, 0o755); err != nil {
 		log.Fatal(err)
 	}
 
+	expanded := map[string]bool{}
 	items := listFiles(sortModifiedDesc)
+	if cfg.TreeView {
+		items = buildTree(items, sortModifiedDesc, expanded)
+	}
 
 	delegate := list.NewDefaultDelegate()
 	l := list.New(items, delegate, 0, 0)
@@ -74,10 +164,23 @@ func initialModel(editor string, themeName string) model {
 			listKeys.TogglePreview,
 			listKeys.ToggleHelpMenu,
 			listKeys.CycleSort,
+			listKeys.Search,
+			listKeys.FollowLink,
+			listKeys.Tags,
+			listKeys.Trash,
+			listKeys.UndoDelete,
+			listKeys.History,
+			listKeys.ToggleTree,
+			listKeys.Daily,
+			listKeys.PrevDay,
+			listKeys.NextDay,
+			listKeys.Encrypt,
+			listKeys.Pin,
+			listKeys.PinnedOnly,
 		}
 	}
 
-	t := getTheme(themeName)
+	t := getTheme(cfg.Theme)
 
 	ti := textinput.New()
 	ti.Placeholder = "filename.md (enter for default)"
@@ -91,25 +194,72 @@ func initialModel(editor string, themeName string) model {
 	di.Width = 40
 	di.Cursor.Style = lipgloss.NewStyle().Foreground(t.Primary)
 
+	si := textinput.New()
+	si.Placeholder = "search note contents"
+	si.CharLimit = 256
+	si.Width = 40
+	si.Cursor.Style = lipgloss.NewStyle().Foreground(t.Primary)
+
+	pi := textinput.New()
+	pi.Placeholder = "passphrase"
+	pi.EchoMode = textinput.EchoPassword
+	pi.EchoCharacter = '•'
+	pi.CharLimit = 256
+	pi.Width = 40
+	pi.Cursor.Style = lipgloss.NewStyle().Foreground(t.Primary)
+
+	fi := textinput.New()
+	fi.Placeholder = "find"
+	fi.Width = 30
+	fi.Cursor.Style = lipgloss.NewStyle().Foreground(t.Primary)
+
+	ri := textinput.New()
+	ri.Placeholder = "replace with"
+	ri.Width = 30
+	ri.Cursor.Style = lipgloss.NewStyle().Foreground(t.Primary)
+
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(t.Primary)
 
 	return model{
-		list:        l,
-		input:       ti,
-		descInput:   di,
-		spinner:     s,
-		keys:        listKeys,
-		viewport:    viewport.New(0, 0),
-		showPreview: true,
-		sortMode:    sortModifiedDesc,
-		editor:      editor,
-		theme:       t,
+		list:           l,
+		input:          ti,
+		descInput:      di,
+		searchInput:    si,
+		passInput:      pi,
+		findInput:      fi,
+		replaceInput:   ri,
+		vaultEvents:    make(chan []string),
+		spinner:        s,
+		keys:           listKeys,
+		viewport:       viewport.New(0, 0),
+		showPreview:    true,
+		sortMode:       sortModifiedDesc,
+		editor:         cfg.Editor,
+		autosave:       time.Duration(cfg.Autosave) * time.Second,
+		previewDefault: cfg.EditorPreview,
+		vimEnabled:     cfg.VimMode,
+		treeView:       cfg.TreeView,
+		expanded:       expanded,
+		dailyPath:      cfg.DailyPath,
+		dailyTemplate:  cfg.DailyTemplate,
+		theme:          t,
 	}
 }
 
 func (m model) loadFileOrImage(path string) tea.Cmd {
+	title, err := filepath.Rel(vaultDir, path)
+	if err != nil {
+		title = filepath.Base(path)
+	}
+	return tea.Batch(m.loadPreview(path), loadLinks(title))
+}
+
+func (m model) loadPreview(path string) tea.Cmd {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return tea.Sequence(clearKittyGraphics(), folderSummary(path))
+	}
 	if isImageFile(path) {
 		listWidth := m.width / 2
 		xOffset := listWidth + 6
@@ -137,12 +287,28 @@ func (m model) loadFileOrImage(path string) tea.Cmd {
 	)
 }
 
+// listItems returns the vault files in the current sort mode, narrowed by the active tag and pin filters.
+func (m model) listItems() []list.Item {
+	items := filterByTags(listFiles(m.sortMode), m.activeTags)
+	if m.pinnedOnly {
+		items = filterPinned(items)
+	}
+	if m.treeView {
+		return buildTree(items, m.sortMode, m.expanded)
+	}
+	return items
+}
+
 func (m model) resolveFilePath(title string) string {
 	return filepath.Join(vaultDir, title)
 }
 
 func (m model) previewHeader() string {
-	title := m.previewHeaderStyle().Render(m.selectedFile)
+	return m.previewHeaderFor(m.selectedFile)
+}
+
+func (m model) previewHeaderFor(name string) string {
+	title := m.previewHeaderStyle().Render(name)
 	line := lipgloss.NewStyle().Foreground(m.theme.Border).Render(
 		fmt.Sprintf("%s", repeatRune('─', max(0, m.viewport.Width-lipgloss.Width(title)))),
 	)
@@ -157,6 +323,51 @@ func (m model) previewFooter() string {
 	return lipgloss.JoinHorizontal(lipgloss.Center, line, info)
 }
 
+// linkTargets lists every note linked to or from the selected note, for the link picker.
+func (m model) linkTargets() []string {
+	if m.linksTitle != m.selectedFile {
+		return nil
+	}
+	var targets []string
+	seen := map[string]bool{}
+	for _, t := range append(append([]string{}, m.linksTo...), m.linksFrom...) {
+		if !seen[t] {
+			seen[t] = true
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// linksPanel renders the "Linked from" section shown under the preview.
+func (m model) linksPanel() string {
+	if m.linksTitle != m.selectedFile || (len(m.linksFrom) == 0 && len(m.linksTo) == 0) {
+		return ""
+	}
+	label := lipgloss.NewStyle().Foreground(m.theme.Secondary).Bold(true)
+	names := lipgloss.NewStyle().Foreground(m.theme.SubText)
+	width := max(0, m.viewport.Width-14)
+
+	var lines []string
+	if len(m.linksFrom) > 0 {
+		lines = append(lines, label.Render(" Linked from ")+names.Render(truncateRunes(strings.Join(m.linksFrom, ", "), width)))
+	}
+	if len(m.linksTo) > 0 {
+		lines = append(lines, label.Render(" Links to    ")+names.Render(truncateRunes(strings.Join(m.linksTo, ", "), width)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// textPreview renders the preview pane for text files: header, content, footer and links.
+func (m model) textPreview() string {
+	panel := m.linksPanel()
+	if panel == "" {
+		return fmt.Sprintf("%s\n%s\n%s", m.previewHeader(), m.viewport.View(), m.previewFooter())
+	}
+	m.viewport.Height = max(1, m.viewport.Height-lipgloss.Height(panel))
+	return fmt.Sprintf("%s\n%s\n%s\n%s", m.previewHeader(), m.viewport.View(), m.previewFooter(), panel)
+}
+
 func repeatRune(r rune, n int) string {
 	if n <= 0 {
 		return ""
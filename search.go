@@ -0,0 +1,211 @@
+// NOTE: Full-text search across every note in the vault
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	maxSearchResults   = 500
+	searchContextLines = 3
+)
+
+type searchMatch struct {
+	title string // vault-relative path, same as item.title
+	line  int    // 1-based
+	col   int    // 0-based rune offset of the match
+	text  string
+}
+
+// searchVault scans the content of every file listFiles returns for query (case-insensitive).
+func searchVault(query string, sMode sortMode) []searchMatch {
+	var matches []searchMatch
+	if query == "" {
+		return matches
+	}
+
+	for _, it := range listFiles(sMode) {
+		title := it.(item).title
+		path := filepath.Join(vaultDir, title)
+		if isImageFile(path) || isEncryptedFile(path) {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil || isBinaryContent(data) {
+			continue
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		lineNo := 0
+		for scanner.Scan() {
+			lineNo++
+			line := scanner.Text()
+			idx, _ := indexFold(line, query)
+			if idx < 0 {
+				continue
+			}
+			matches = append(matches, searchMatch{
+				title: title,
+				line:  lineNo,
+				col:   len([]rune(line[:idx])),
+				text:  line,
+			})
+			if len(matches) >= maxSearchResults {
+				return matches
+			}
+		}
+	}
+	return matches
+}
+
+func runSearch(query string, sMode sortMode) tea.Cmd {
+	return func() tea.Msg {
+		return searchResultsMsg{query: query, matches: searchVault(query, sMode)}
+	}
+}
+
+// isBinaryContent reports whether data looks like something other than text.
+func isBinaryContent(data []byte) bool {
+	head := data[:min(len(data), 512)]
+	return bytes.IndexByte(head, 0) >= 0
+}
+
+/*
+	NOTE:
+
+indexFold finds needle in s ignoring case, returning the byte offset and
+byte length of the match in s itself, or -1. Case folding can change how
+many bytes a letter takes (Ⱥ is 2, ⱥ is 3), so offsets into a lowercased
+copy can't be used to slice the original.
+*/
+func indexFold(s, needle string) (int, int) {
+	n := utf8.RuneCountInString(needle)
+	if n == 0 {
+		return -1, 0
+	}
+	for i := range s {
+		end := i
+		for k := 0; k < n && end < len(s); k++ {
+			_, size := utf8.DecodeRuneInString(s[end:])
+			end += size
+		}
+		if strings.EqualFold(s[i:end], needle) {
+			return i, end - i
+		}
+	}
+	return -1, 0
+}
+
+// highlightMatches renders every case-insensitive occurrence of query in line with style.
+func highlightMatches(line, query string, style lipgloss.Style) string {
+	if query == "" {
+		return line
+	}
+
+	var b strings.Builder
+	for {
+		idx, size := indexFold(line, query)
+		if idx < 0 {
+			b.WriteString(line)
+			break
+		}
+		b.WriteString(line[:idx])
+		b.WriteString(style.Render(line[idx : idx+size]))
+		line = line[idx+size:]
+	}
+	return b.String()
+}
+
+// searchContext renders the lines around a match with line numbers and the match highlighted.
+func (m model) searchContext(match searchMatch) string {
+	data, err := os.ReadFile(m.resolveFilePath(match.title))
+	if err != nil {
+		return "Error reading file"
+	}
+	lines := strings.Split(string(data), "\n")
+
+	start := max(0, match.line-1-searchContextLines)
+	end := min(len(lines), match.line+searchContextLines)
+
+	numStyle := lipgloss.NewStyle().Foreground(m.theme.Muted)
+	curNumStyle := lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true)
+	hlStyle := lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true).Underline(true)
+
+	var b strings.Builder
+	for i := start; i < end; i++ {
+		num := fmt.Sprintf("%4d │ ", i+1)
+		if i == match.line-1 {
+			b.WriteString(curNumStyle.Render(num))
+			b.WriteString(highlightMatches(lines[i], m.searchInput.Value(), hlStyle))
+		} else {
+			b.WriteString(numStyle.Render(num))
+			b.WriteString(lines[i])
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// searchResultsView renders the match list shown on the left in search mode.
+func (m model) searchResultsView(width, height int) string {
+	normal := lipgloss.NewStyle().Foreground(m.theme.Text).PaddingLeft(2)
+	location := lipgloss.NewStyle().Foreground(m.theme.SubText)
+	selected := lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder(), false, false, false, true).
+		BorderForeground(m.theme.Accent).
+		Foreground(m.theme.Accent).
+		Bold(true).
+		PaddingLeft(1)
+
+	if len(m.searchResults) == 0 {
+		msg := "No matches"
+		if m.searchInput.Value() == "" {
+			msg = "Type to search note contents"
+		}
+		return lipgloss.NewStyle().Width(width).Foreground(m.theme.Muted).PaddingLeft(2).Render(msg)
+	}
+
+	height = max(1, height)
+	top := max(0, min(m.searchIdx-height/2, len(m.searchResults)-height))
+	end := min(len(m.searchResults), top+height)
+
+	var b strings.Builder
+	for i := top; i < end; i++ {
+		r := m.searchResults[i]
+		loc := truncateRunes(fmt.Sprintf("%s:%d", r.title, r.line), max(0, width-3))
+		snippet := truncateRunes(strings.TrimSpace(r.text), max(0, width-3-len([]rune(loc))-2))
+		if i == m.searchIdx {
+			b.WriteString(selected.Render(loc + "  " + snippet))
+		} else {
+			b.WriteString(normal.Render(location.Render(loc) + "  " + snippet))
+		}
+		b.WriteString("\n")
+	}
+
+	count := lipgloss.NewStyle().Foreground(m.theme.Muted).PaddingLeft(2).
+		Render(fmt.Sprintf("%d matches", len(m.searchResults)))
+	return lipgloss.NewStyle().Width(width).Render(b.String() + "\n" + count)
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	if n <= 1 {
+		return string(r[:n])
+	}
+	return string(r[:n-1]) + "…"
+}
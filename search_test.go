@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndexFold(t *testing.T) {
+	tests := []struct {
+		s, needle string
+		idx, size int
+	}{
+		{"Hello World", "world", 6, 5},
+		{"hello", "HELLO", 0, 5},
+		{"nothing here", "zzz", -1, 0},
+		// Ⱥ is 2 bytes but lowercases to the 3 byte ⱥ.
+		{"ȺȺȺȺȺȺ x", "x", 13, 1},
+		{"ȺȺȺȺȺȺ x", "ⱥⱥ", 0, 4},
+		{"straße", "SSE", -1, 0},
+		{"", "a", -1, 0},
+		{"abc", "", -1, 0},
+	}
+	for _, tt := range tests {
+		idx, size := indexFold(tt.s, tt.needle)
+		if idx != tt.idx || size != tt.size {
+			t.Errorf("indexFold(%q, %q) = %d, %d; want %d, %d", tt.s, tt.needle, idx, size, tt.idx, tt.size)
+		}
+	}
+}
+
+func TestSearchVaultNonASCII(t *testing.T) {
+	vaultDir = t.TempDir()
+	content := "first line\nȺȺȺȺȺȺ x marks the spot\n"
+	if err := os.WriteFile(filepath.Join(vaultDir, "note.md"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	matches := searchVault("X MARKS", sortNameAsc)
+	if len(matches) != 1 {
+		t.Fatalf("got %d matches, want 1", len(matches))
+	}
+	if m := matches[0]; m.line != 2 || m.col != 7 {
+		t.Errorf("match at line %d col %d, want line 2 col 7", m.line, m.col)
+	}
+}
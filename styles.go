@@ -2,7 +2,15 @@
 
 package main
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
 
 type Theme struct {
 	Primary   lipgloss.Color
@@ -166,3 +174,111 @@ func getTheme(name string) Theme {
 	}
 	return themes["default"]
 }
+
+// ThemeConfig is a [themes.<name>] table in config.toml. Unset colours come from inherit.
+type ThemeConfig struct {
+	Inherit   string `toml:"inherit"` // built-in theme to start from, default if empty
+	Primary   string `toml:"primary"`
+	Secondary string `toml:"secondary"`
+	Border    string `toml:"border"`
+	Accent    string `toml:"accent"`
+	Muted     string `toml:"muted"`
+	MoreMuted string `toml:"more_muted"`
+	Text      string `toml:"text"`
+	SubText   string `toml:"sub_text"`
+}
+
+// parseColor accepts a hex colour (#rgb or #rrggbb) or an ANSI colour number (0-255).
+func parseColor(s string) (lipgloss.Color, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "#") {
+		hex := s[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if _, err := strconv.ParseUint(hex, 16, 32); err == nil && len(hex) == 6 {
+			return lipgloss.Color("#" + strings.ToLower(hex)), nil
+		}
+	} else if n, err := strconv.ParseUint(s, 10, 8); err == nil {
+		// ParseUint takes no sign; lipgloss only understands the plain number, so "007" becomes "7".
+		return lipgloss.Color(strconv.FormatUint(n, 10)), nil
+	}
+	return "", fmt.Errorf("%q is not a hex (#rrggbb) or ANSI (0-255) colour", s)
+}
+
+/*
+	NOTE:
+
+buildTheme turns a config table into a Theme, starting from a built-in
+theme and overriding the colours that are set. Every bad colour is
+reported, not just the first.
+*/
+func buildTheme(name string, tc ThemeConfig, builtin map[string]Theme) (Theme, error) {
+	base := "default"
+	if tc.Inherit != "" {
+		base = tc.Inherit
+	}
+	t, ok := builtin[base]
+	if !ok {
+		return Theme{}, fmt.Errorf("theme %q: inherit: unknown built-in theme %q", name, base)
+	}
+
+	var errs []error
+	for _, f := range []struct {
+		name  string
+		value string
+		dst   *lipgloss.Color
+	}{
+		{"primary", tc.Primary, &t.Primary},
+		{"secondary", tc.Secondary, &t.Secondary},
+		{"border", tc.Border, &t.Border},
+		{"accent", tc.Accent, &t.Accent},
+		{"muted", tc.Muted, &t.Muted},
+		{"more_muted", tc.MoreMuted, &t.MoreMuted},
+		{"text", tc.Text, &t.Text},
+		{"sub_text", tc.SubText, &t.SubText},
+	} {
+		if f.value == "" {
+			continue
+		}
+		c, err := parseColor(f.value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("theme %q: %s: %w", name, f.name, err))
+			continue
+		}
+		*f.dst = c
+	}
+	return t, errors.Join(errs...)
+}
+
+// loadThemes adds the config's themes to the built-in ones and checks that the chosen theme exists.
+func loadThemes(cfg Config) error {
+	builtin := make(map[string]Theme, len(themes))
+	for name, t := range themes {
+		builtin[name] = t
+	}
+
+	names := make([]string, 0, len(cfg.Themes))
+	for name := range cfg.Themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		t, err := buildTheme(name, cfg.Themes[name], builtin)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		themes[name] = t
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("bad theme in %s:\n%w", configPath(), err)
+	}
+
+	if _, ok := themes[cfg.Theme]; !ok && cfg.Theme != "" {
+		return fmt.Errorf("unknown theme %q", cfg.Theme)
+	}
+	return nil
+}
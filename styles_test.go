@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		in   string
+		want lipgloss.Color
+		ok   bool
+	}{
+		{"#FF8800", "#ff8800", true},
+		{"#f80", "#ff8800", true},
+		{" 212 ", "212", true},
+		{"0", "0", true},
+		{"255", "255", true},
+		{"007", "7", true},
+		{"256", "", false},
+		{"+5", "", false},
+		{"-0", "", false},
+		{"-1", "", false},
+		{"#ff88", "", false},
+		{"#gggggg", "", false},
+		{"red", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, err := parseColor(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseColor(%q) = %q, %v; want %q, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// NOTE: Syntax highlighting for the inbuilt editor, coloured from the active theme
+
+package main
+
+import (
+	"time"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// syntaxSyncLimit is the largest buffer highlighted on every key press; bigger ones wait for a pause in typing.
+const syntaxSyncLimit = 16 << 10
+
+// editorSyntaxTickMsg fires once typing pauses in a large buffer; stale ticks carry an old seq.
+type editorSyntaxTickMsg struct {
+	seq int
+}
+
+// editorSyntaxMsg carries the colours worked out for value.
+type editorSyntaxMsg struct {
+	seq    int
+	value  string
+	colors [][]lipgloss.Color
+}
+
+// editorLexer picks a chroma lexer from the note's extension, or nil when there isn't one.
+func editorLexer(path string) chroma.Lexer {
+	lexer := lexers.Match(plainName(path))
+	if lexer == nil {
+		return nil
+	}
+	return chroma.Coalesce(lexer)
+}
+
+// tokenColor maps a chroma token onto the theme, so highlighting matches the rest of the UI.
+func tokenColor(t Theme, tt chroma.TokenType) lipgloss.Color {
+	switch {
+	case tt.InCategory(chroma.Comment):
+		return t.Muted
+	case tt.InCategory(chroma.Keyword), tt == chroma.GenericHeading, tt == chroma.GenericSubheading:
+		return t.Primary
+	case tt.InCategory(chroma.LiteralString), tt.InCategory(chroma.LiteralNumber),
+		tt == chroma.GenericEmph, tt == chroma.GenericStrong:
+		return t.Accent
+	case tt == chroma.NameFunction, tt == chroma.NameClass, tt == chroma.NameBuiltin,
+		tt == chroma.NameTag, tt == chroma.NameAttribute, tt == chroma.NameDecorator:
+		return t.Secondary
+	case tt.InCategory(chroma.Operator), tt.InCategory(chroma.Punctuation):
+		return t.SubText
+	}
+	return t.Text
+}
+
+/*
+	NOTE:
+
+highlightSyntax colours text rune by rune, one slice per line, so the
+editor can look colours up by line and column. It is worked out once per
+change to the buffer rather than on every redraw.
+*/
+func highlightSyntax(lexer chroma.Lexer, t Theme, text string) [][]lipgloss.Color {
+	if lexer == nil {
+		return nil
+	}
+	it, err := lexer.Tokenise(nil, text)
+	if err != nil {
+		return nil
+	}
+	lines := [][]lipgloss.Color{nil}
+	for tok := it(); tok != chroma.EOF; tok = it() {
+		color := tokenColor(t, tok.Type)
+		for _, r := range tok.Value {
+			if r == '\n' {
+				lines = append(lines, nil)
+				continue
+			}
+			lines[len(lines)-1] = append(lines[len(lines)-1], color)
+		}
+	}
+	return lines
+}
+
+/*
+	NOTE:
+
+refreshSyntax re-highlights the buffer if it changed since it was last
+highlighted. Small notes are done straight away; large ones keep their old
+colours until typing pauses for previewDebounce, then are tokenised off the
+UI goroutine.
+*/
+func (m *model) refreshSyntax() tea.Cmd {
+	value := m.editorContent.Value()
+	if m.editorLexer == nil || value == m.syntaxFor {
+		return nil
+	}
+	m.syntaxSeq++
+	if len(value) <= syntaxSyncLimit {
+		m.editorSyntax = highlightSyntax(m.editorLexer, m.theme, value)
+		m.syntaxFor = value
+		return nil
+	}
+	seq := m.syntaxSeq
+	return tea.Tick(previewDebounce, func(time.Time) tea.Msg {
+		return editorSyntaxTickMsg{seq: seq}
+	})
+}
+
+func (m model) highlightEditor() tea.Cmd {
+	seq, value, lexer, theme := m.syntaxSeq, m.editorContent.Value(), m.editorLexer, m.theme
+	return func() tea.Msg {
+		return editorSyntaxMsg{seq: seq, value: value, colors: highlightSyntax(lexer, theme, value)}
+	}
+}
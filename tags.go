@@ -0,0 +1,183 @@
+// NOTE: Tags from YAML frontmatter (tags: ...) and inline #hashtags
+
+package main
+
+import (
+	"bufio"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+var hashtagRe = regexp.MustCompile(`(?:^|[\s(])#([\p{L}\p{N}_][\p{L}\p{N}_\-/]*)`)
+
+// parseTags returns the lowercased, de-duplicated tags of a note in the order they appear.
+func parseTags(content string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	add := func(t string) {
+		t = strings.ToLower(strings.Trim(strings.TrimSpace(t), `"'#`))
+		if t == "" || seen[t] {
+			return
+		}
+		seen[t] = true
+		tags = append(tags, t)
+	}
+
+	body := content
+	if fm, rest, ok := splitFrontmatter(content); ok {
+		for _, t := range frontmatterTags(fm) {
+			add(t)
+		}
+		body = rest
+	}
+
+	inFence := false
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		for _, m := range hashtagRe.FindAllStringSubmatch(line, -1) {
+			// Skip purely numeric tags like issue references (#42)
+			if strings.Trim(m[1], "0123456789") == "" {
+				continue
+			}
+			add(m[1])
+		}
+	}
+	return tags
+}
+
+// splitFrontmatter separates a leading "---" delimited block from the rest of the note.
+func splitFrontmatter(content string) (string, string, bool) {
+	content = strings.TrimPrefix(content, "\ufeff")
+	if !strings.HasPrefix(content, "---\n") && !strings.HasPrefix(content, "---\r\n") {
+		return "", content, false
+	}
+	rest := content[strings.Index(content, "\n")+1:]
+	for offset := 0; offset < len(rest); {
+		end := strings.Index(rest[offset:], "\n")
+		var line string
+		if end < 0 {
+			line = rest[offset:]
+			end = len(rest) - offset
+		} else {
+			line = rest[offset : offset+end]
+		}
+		if strings.TrimRight(line, "\r") == "---" {
+			return rest[:offset], rest[min(len(rest), offset+end+1):], true
+		}
+		offset += end + 1
+	}
+	return "", content, false
+}
+
+/*
+	NOTE:
+
+frontmatterTags understands the three common spellings:
+
+	tags: [a, b]
+	tags: a, b
+	tags:
+	  - a
+	  - b
+*/
+func frontmatterTags(fm string) []string {
+	var tags []string
+	lines := strings.Split(fm, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		keyName, value, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(keyName) != "tags" || strings.HasPrefix(line, " ") {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value != "" {
+			value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+			for _, t := range strings.Split(value, ",") {
+				tags = append(tags, strings.Fields(t)...)
+			}
+			continue
+		}
+		for i+1 < len(lines) {
+			next := strings.TrimSpace(lines[i+1])
+			if !strings.HasPrefix(next, "- ") {
+				break
+			}
+			tags = append(tags, strings.TrimSpace(strings.TrimPrefix(next, "- ")))
+			i++
+		}
+	}
+	return tags
+}
+
+func formatTags(tags []string) string {
+	return "#" + strings.Join(tags, " #")
+}
+
+type tagCount struct {
+	name  string
+	count int
+}
+
+// vaultTags counts every tag used in items, most used first.
+func vaultTags(items []list.Item) []tagCount {
+	counts := map[string]int{}
+	for _, it := range items {
+		for _, t := range it.(item).tags {
+			counts[t]++
+		}
+	}
+	tags := make([]tagCount, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, tagCount{name: name, count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].count != tags[j].count {
+			return tags[i].count > tags[j].count
+		}
+		return tags[i].name < tags[j].name
+	})
+	return tags
+}
+
+// hasAllTags reports whether i carries every tag in want.
+func (i item) hasAllTags(want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, t := range i.tags {
+			if t == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// filterByTags narrows items to those carrying every tag in want.
+func filterByTags(items []list.Item, want []string) []list.Item {
+	if len(want) == 0 {
+		return items
+	}
+	var filtered []list.Item
+	for _, it := range items {
+		if it.(item).hasAllTags(want) {
+			filtered = append(filtered, it)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,87 @@
+// NOTE: Note templates. Anything inside <vault>/.templates can be picked when creating a note.
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const cursorMarker = "{{cursor}}"
+
+func templatesDir() string {
+	return filepath.Join(vaultDir, ".templates")
+}
+
+// listTemplates returns template names relative to the templates directory, sorted by name.
+func listTemplates() []string {
+	var names []string
+	root := templatesDir()
+
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Name()[0] == '.' && path != root {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		names = append(names, rel)
+		return nil
+	})
+
+	sort.Strings(names)
+	return names
+}
+
+/*
+	NOTE:
+
+applyTemplate fills in the template variables for the note at path.
+Supported: {{date}}, {{time}}, {{title}}, {{desc}} and {{cursor}}.
+The cursor marker is removed and its position returned as (row, col),
+or (-1, -1) if the template has none.
+*/
+func applyTemplate(content, path, desc string, now time.Time) (string, int, int) {
+	title := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	r := strings.NewReplacer(
+		"{{date}}", now.Format("2006-01-02"),
+		"{{time}}", now.Format("15:04"),
+		"{{title}}", title,
+		"{{desc}}", desc,
+	)
+	content = r.Replace(content)
+
+	idx := strings.Index(content, cursorMarker)
+	if idx < 0 {
+		return content, -1, -1
+	}
+
+	before := content[:idx]
+	row := strings.Count(before, "\n")
+	col := len([]rune(before[strings.LastIndex(before, "\n")+1:]))
+	content = before + strings.ReplaceAll(content[idx+len(cursorMarker):], cursorMarker, "")
+	return content, row, col
+}
+
+func readTemplate(name string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(templatesDir(), name))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,169 @@
+// NOTE: Deleted notes go to <vault>/.trash so they can be restored
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	trashInfoFile    = "info.json"
+	trashHistoryName = ".history"
+)
+
+/*
+	NOTE:
+
+Every deleted note gets its own directory .trash/<id>/ holding the file
+itself, its history under .history/ and an info.json with where it came
+from, when it was deleted, its description and whether it was pinned, so
+restoring puts everything back the way it was. A note's name never starts
+with a dot, so .history can't clash with it.
+*/
+type trashEntry struct {
+	ID      string    `json:"-"`
+	Path    string    `json:"path"`
+	Deleted time.Time `json:"deleted"`
+	Desc    string    `json:"desc,omitempty"`
+	Pinned  bool      `json:"pinned,omitempty"`
+}
+
+func trashDir() string {
+	return filepath.Join(vaultDir, ".trash")
+}
+
+// moveToTrash moves the note at path, its description and its history into the trash.
+func moveToTrash(path string) (trashEntry, error) {
+	rel, err := filepath.Rel(vaultDir, path)
+	if err != nil {
+		return trashEntry{}, err
+	}
+
+	entry := trashEntry{
+		ID:      strconv.FormatInt(time.Now().UnixNano(), 10),
+		Path:    rel,
+		Deleted: time.Now(),
+		Desc:    readMetaDesc(path),
+		Pinned:  isPinned(path),
+	}
+	dir := filepath.Join(trashDir(), entry.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return trashEntry{}, err
+	}
+
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return trashEntry{}, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, trashInfoFile), data, 0o644); err != nil {
+		return trashEntry{}, err
+	}
+	if err := os.Rename(path, filepath.Join(dir, filepath.Base(path))); err != nil {
+		os.RemoveAll(dir)
+		return trashEntry{}, err
+	}
+	// Left behind, the history would turn up as the history of the next note given this name.
+	os.Rename(historyDir(path), filepath.Join(dir, trashHistoryName))
+	deleteMeta(path)
+	return entry, nil
+}
+
+// listTrash returns everything in the trash, most recently deleted first.
+func listTrash() []trashEntry {
+	dirs, err := os.ReadDir(trashDir())
+	if err != nil {
+		return nil
+	}
+
+	var entries []trashEntry
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(trashDir(), d.Name(), trashInfoFile))
+		if err != nil {
+			continue
+		}
+		var e trashEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			continue
+		}
+		e.ID = d.Name()
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Deleted.After(entries[j].Deleted)
+	})
+	return entries
+}
+
+// restoreFromTrash puts a trashed note back at its original path, or next to it if that
+// path has been taken since. It returns the vault-relative path it was restored to.
+func restoreFromTrash(e trashEntry) (string, error) {
+	dir := filepath.Join(trashDir(), e.ID)
+	src := filepath.Join(dir, filepath.Base(e.Path))
+
+	dest := filepath.Join(vaultDir, e.Path)
+	ext := filepath.Ext(dest)
+	base := strings.TrimSuffix(dest, ext)
+	for n := 1; ; n++ {
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			break
+		}
+		if n == 1 {
+			dest = fmt.Sprintf("%s (restored)%s", base, ext)
+		} else {
+			dest = fmt.Sprintf("%s (restored %d)%s", base, n, ext)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.Rename(src, dest); err != nil {
+		return "", err
+	}
+	writeMetaDesc(dest, e.Desc)
+	if e.Pinned {
+		setPinned(dest, true)
+	}
+	if _, err := os.Stat(historyDir(dest)); os.IsNotExist(err) {
+		os.MkdirAll(historyRoot(), 0o755)
+		os.Rename(filepath.Join(dir, trashHistoryName), historyDir(dest))
+	}
+	os.RemoveAll(dir)
+
+	rel, _ := filepath.Rel(vaultDir, dest)
+	return rel, nil
+}
+
+// purgeTrash permanently deletes a trashed note and its history.
+func purgeTrash(e trashEntry) error {
+	// Notes trashed before history moved into the trash left it in .history; it goes too, unless a new note has the name.
+	path := filepath.Join(vaultDir, e.Path)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		os.RemoveAll(historyDir(path))
+	}
+	return os.RemoveAll(filepath.Join(trashDir(), e.ID))
+}
+
+// purgeOldTrash permanently deletes everything trashed more than days ago. days <= 0 keeps everything.
+func purgeOldTrash(days int) {
+	if days <= 0 {
+		return
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+	for _, e := range listTrash() {
+		if e.Deleted.Before(cutoff) {
+			purgeTrash(e)
+		}
+	}
+}
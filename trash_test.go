@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTrashKeepsHistory(t *testing.T) {
+	writeVault(t, map[string]string{"work/a.md": "v2"})
+	path := filepath.Join(vaultDir, "work", "a.md")
+	if err := saveSnapshot(path, []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := setPinned(path, true); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := moveToTrash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(listSnapshots(path)); n != 0 {
+		t.Fatalf("%d snapshots left at the trashed note's path, want 0", n)
+	}
+
+	// A new note with the same name starts with no history.
+	if err := os.WriteFile(path, []byte("new"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(listSnapshots(path)); n != 0 {
+		t.Fatalf("new note has %d snapshots, want 0", n)
+	}
+	if isPinned(path) {
+		t.Fatal("new note inherited the trashed note's pin")
+	}
+	os.Remove(path)
+
+	rel, err := restoreFromTrash(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(listSnapshots(filepath.Join(vaultDir, rel))); n != 1 {
+		t.Fatalf("restored note has %d snapshots, want 1", n)
+	}
+	if !isPinned(filepath.Join(vaultDir, rel)) {
+		t.Fatal("restored note lost its pin")
+	}
+
+	e, err = moveToTrash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := purgeTrash(e); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(trashDir(), e.ID)); !os.IsNotExist(err) {
+		t.Errorf("trash entry still there after purging: %v", err)
+	}
+	if n := len(listSnapshots(path)); n != 0 {
+		t.Errorf("%d snapshots left after purging, want 0", n)
+	}
+}
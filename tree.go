@@ -0,0 +1,156 @@
+// NOTE: Optional tree view, folders become collapsible list items
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type treeNode struct {
+	folder item
+	dirs   map[string]*treeNode
+	files  []item
+}
+
+func newTreeNode(path string, depth int) *treeNode {
+	return &treeNode{
+		folder: item{title: path, isDir: true, depth: depth},
+		dirs:   map[string]*treeNode{},
+	}
+}
+
+/*
+	NOTE:
+
+buildTree turns the flat, already sorted listFiles result into folder
+and file rows. Folders come first inside every folder and are ordered
+with the same sort mode, using their newest note's times, while files
+keep the order they arrived in. Only folders in expanded are opened.
+*/
+func buildTree(items []list.Item, sMode sortMode, expanded map[string]bool) []list.Item {
+	root := newTreeNode("", -1)
+
+	for _, it := range items {
+		i := it.(item)
+		node := root
+		parts := strings.Split(filepath.ToSlash(i.title), "/")
+		for d, part := range parts[:len(parts)-1] {
+			child, ok := node.dirs[part]
+			if !ok {
+				child = newTreeNode(filepath.Join(node.folder.title, part), d)
+				node.dirs[part] = child
+			}
+			child.folder.count++
+			if i.modTime.After(child.folder.modTime) {
+				child.folder.modTime = i.modTime
+			}
+			if i.creTime.After(child.folder.creTime) {
+				child.folder.creTime = i.creTime
+			}
+			node = child
+		}
+		i.depth = len(parts) - 1
+		node.files = append(node.files, i)
+	}
+
+	var rows []list.Item
+	var walk func(n *treeNode)
+	walk = func(n *treeNode) {
+		dirs := make([]*treeNode, 0, len(n.dirs))
+		for _, d := range n.dirs {
+			dirs = append(dirs, d)
+		}
+		sort.Slice(dirs, func(a, b int) bool {
+			return itemLess(dirs[a].folder, dirs[b].folder, sMode)
+		})
+		for _, d := range dirs {
+			rows = append(rows, d.folder)
+			if expanded[d.folder.title] {
+				walk(d)
+			}
+		}
+		for _, f := range n.files {
+			rows = append(rows, f)
+		}
+	}
+	walk(root)
+	return rows
+}
+
+// expandParents opens every folder above title so it is visible in the tree.
+func expandParents(title string, expanded map[string]bool) {
+	for dir := filepath.Dir(title); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		expanded[dir] = true
+	}
+}
+
+// treeRow is how an item is displayed in the tree: indented, with folders marked.
+type treeRow struct {
+	item
+	expanded bool
+}
+
+func (r treeRow) Title() string {
+	indent := strings.Repeat("  ", r.depth)
+	if r.isDir {
+		marker := "▸"
+		if r.expanded {
+			marker = "▾"
+		}
+		return fmt.Sprintf("%s%s %s/", indent, marker, filepath.Base(r.title))
+	}
+	if r.pinned {
+		return indent + filepath.Base(r.title) + pinMarker
+	}
+	return indent + filepath.Base(r.title)
+}
+
+func (r treeRow) Description() string {
+	return strings.Repeat("  ", r.depth) + r.item.Description()
+}
+
+// treeDelegate renders items as treeRows with the default delegate's styling.
+type treeDelegate struct {
+	list.DefaultDelegate
+	expanded map[string]bool
+}
+
+func (d treeDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	if i, ok := listItem.(item); ok {
+		listItem = treeRow{item: i, expanded: d.expanded[i.title]}
+	}
+	d.DefaultDelegate.Render(w, m, index, listItem)
+}
+
+// folderSummary lists a folder's contents for the preview pane.
+func folderSummary(path string) tea.Cmd {
+	return func() tea.Msg {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return fileLoadedMsg{content: "Error reading folder"}
+		}
+		var b strings.Builder
+		for _, e := range entries {
+			if e.Name()[0] == '.' {
+				continue
+			}
+			if e.IsDir() {
+				b.WriteString(e.Name() + "/\n")
+			} else {
+				b.WriteString(e.Name() + "\n")
+			}
+		}
+		if b.Len() == 0 {
+			return fileLoadedMsg{content: "Empty folder"}
+		}
+		return fileLoadedMsg{content: b.String()}
+	}
+}
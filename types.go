@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/charmbracelet/bubbles/list"
@@ -8,10 +9,20 @@ import (
 
 // NOTE: Inbuilt editor using textarea component
 
-type (
-	editorSavedMsg  struct{}
-	editorClosedMsg struct{}
-)
+type editorClosedMsg struct{}
+
+// editorSavedMsg reports a finished inbuilt editor save of content to path.
+type editorSavedMsg struct {
+	path    string
+	content string
+	auto    bool
+	err     error
+}
+
+// autosaveTickMsg fires every autosave interval while editor session is open.
+type autosaveTickMsg struct {
+	session int
+}
 
 // Tea messages
 
@@ -19,6 +30,11 @@ type fileEditedMsg struct {
 	err error
 }
 
+// passphraseNeededMsg is sent instead of fileLoadedMsg when an encrypted note can't be shown yet.
+type passphraseNeededMsg struct {
+	path string
+}
+
 type fileLoadedMsg struct {
 	content string
 }
@@ -27,6 +43,17 @@ type imageRenderedMsg struct{}
 
 type clearViewportMsg struct{}
 
+type linksLoadedMsg struct {
+	title string
+	to    []string
+	from  []string
+}
+
+type searchResultsMsg struct {
+	query   string
+	matches []searchMatch
+}
+
 // List item
 
 type item struct {
@@ -34,11 +61,45 @@ type item struct {
 	desc    string
 	modTime time.Time
 	creTime time.Time
+	tags    []string
+	pinned  bool
+
+	// Tree view only: folders are items too.
+	isDir bool
+	depth int
+	count int
 }
 
-func (i item) Title() string       { return i.title }
-func (i item) Description() string { return i.desc }
-func (i item) FilterValue() string { return i.title }
+// pinMarker follows the title of pinned notes. It goes after the title so filter match highlighting still lines up.
+const pinMarker = " 📌"
+
+func (i item) Title() string {
+	if i.pinned {
+		return i.title + pinMarker
+	}
+	return i.title
+}
+
+func (i item) Description() string {
+	if i.isDir {
+		if i.count == 1 {
+			return "1 note"
+		}
+		return fmt.Sprintf("%d notes", i.count)
+	}
+	if len(i.tags) == 0 {
+		return i.desc
+	}
+	return i.desc + "  " + formatTags(i.tags)
+}
+
+// FilterValue includes tags so "/" matches on both the title and #tags.
+func (i item) FilterValue() string {
+	if len(i.tags) == 0 {
+		return i.title
+	}
+	return i.title + " " + formatTags(i.tags)
+}
 
 // Sort modes
 
@@ -72,5 +133,25 @@ func (s sortMode) String() string {
 	}
 }
 
+// parseSortMode maps the names accepted on the command line to a sort mode.
+func parseSortMode(name string) (sortMode, error) {
+	switch name {
+	case "", "modified":
+		return sortModifiedDesc, nil
+	case "modified-asc":
+		return sortModifiedAsc, nil
+	case "created":
+		return sortCreatedDesc, nil
+	case "created-asc":
+		return sortCreatedAsc, nil
+	case "name":
+		return sortNameAsc, nil
+	case "name-desc":
+		return sortNameDesc, nil
+	default:
+		return 0, fmt.Errorf("unknown sort mode %q (want modified, modified-asc, created, created-asc, name or name-desc)", name)
+	}
+}
+
 // Ensure item satisfies list.Item at compile time.
 var _ list.Item = item{}
@@ -0,0 +1,142 @@
+// NOTE: Undo/redo for the inbuilt editor
+
+package main
+
+import (
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// maxUndo is how many edit groups the editor remembers.
+	maxUndo = 200
+	// undoGroupGap is the pause after which typing starts a new undo group.
+	undoGroupGap = time.Second
+)
+
+// editState is the editor buffer and cursor at one point in time.
+type editState struct {
+	value    string
+	row, col int
+}
+
+type editKind int
+
+const (
+	editOther editKind = iota // never grouped: newlines, line kills, pastes, replaces
+	editInsert
+	editDelete
+)
+
+/*
+	NOTE:
+
+editHistory keeps whole-buffer states rather than diffs; notes are small
+and it makes undo impossible to get subtly wrong. Consecutive edits of the
+same kind (typing a word, holding backspace) share one entry, so a single
+undo takes back a word rather than a character.
+*/
+type editHistory struct {
+	undo, redo []editState
+	lastKind   editKind
+	lastEdit   time.Time
+	wordEnded  bool
+}
+
+func currentEditState(ta textarea.Model) editState {
+	li := ta.LineInfo()
+	return editState{value: ta.Value(), row: ta.Line(), col: li.StartColumn + li.ColumnOffset}
+}
+
+func restoreEditState(ta *textarea.Model, s editState) {
+	ta.SetValue(s.value)
+	moveEditorCursor(ta, s.row, s.col)
+}
+
+// record notes that the buffer is about to change away from before.
+func (h *editHistory) record(before editState, kind editKind, endsWord bool, now time.Time) {
+	grouped := kind != editOther && kind == h.lastKind && !h.wordEnded &&
+		now.Sub(h.lastEdit) < undoGroupGap && len(h.undo) > 0
+	if !grouped {
+		h.undo = append(h.undo, before)
+		if len(h.undo) > maxUndo {
+			h.undo = h.undo[len(h.undo)-maxUndo:]
+		}
+	}
+	h.redo = nil
+	h.lastKind = kind
+	h.lastEdit = now
+	h.wordEnded = endsWord
+}
+
+// discardLast drops the newest undo entry, for a change that turned out to change nothing.
+func (h *editHistory) discardLast() {
+	if len(h.undo) > 0 {
+		h.undo = h.undo[:len(h.undo)-1]
+	}
+}
+
+// breakGroup makes the next edit start a fresh undo entry.
+func (h *editHistory) breakGroup() {
+	h.lastKind = editOther
+}
+
+func (h *editHistory) undoTo(cur editState) (editState, bool) {
+	if len(h.undo) == 0 {
+		return editState{}, false
+	}
+	prev := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, cur)
+	h.breakGroup()
+	return prev, true
+}
+
+func (h *editHistory) redoTo(cur editState) (editState, bool) {
+	if len(h.redo) == 0 {
+		return editState{}, false
+	}
+	next := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, cur)
+	h.breakGroup()
+	return next, true
+}
+
+// classifyEdit decides how a key press that changed the buffer groups with its neighbours.
+func classifyEdit(msg tea.KeyMsg) (kind editKind, endsWord bool) {
+	switch msg.Type {
+	case tea.KeyRunes:
+		if msg.Paste || len(msg.Runes) != 1 {
+			return editOther, true
+		}
+		return editInsert, unicode.IsSpace(msg.Runes[0])
+	case tea.KeySpace:
+		return editInsert, true
+	case tea.KeyBackspace, tea.KeyDelete:
+		return editDelete, false
+	}
+	return editOther, true
+}
+
+// updateEditor passes a key to the textarea, recording the change for undo.
+func (m model) updateEditor(msg tea.KeyMsg) (model, tea.Cmd) {
+	before := currentEditState(m.editorContent)
+	var cmd tea.Cmd
+	m.editorContent, cmd = m.editorContent.Update(msg)
+	// A vim insert is recorded as a whole when it starts.
+	if m.vimEnabled && m.vimState == vimInsert {
+		return m, cmd
+	}
+	if m.editorContent.Value() != before.value {
+		kind, endsWord := classifyEdit(msg)
+		m.editorUndo.record(before, kind, endsWord, time.Now())
+	} else {
+		// Moving the cursor ends the current group.
+		m.editorUndo.breakGroup()
+	}
+	return m, cmd
+}
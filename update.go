@@ -9,12 +9,15 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/muesli/reflow/wordwrap"
 )
 
@@ -53,6 +56,25 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.viewport.Height = msg.Height - 10
 		m.list.SetSize(listWidth, msg.Height-5)
 
+		if m.editorMode {
+			m.editorContent.SetHeight(m.editorHeight())
+			m.scrollEditor()
+			if m.editorPreview {
+				m.resizeEditorPreview()
+				m.previewSeq++
+				clearCmd = tea.Batch(clearCmd, m.renderEditorPreview())
+			}
+		}
+
+		if m.searchMode && m.ready {
+			m.setSearchPreview()
+			return m, clearCmd
+		}
+		if m.historyMode && m.ready {
+			m.setHistoryPreview()
+			return m, clearCmd
+		}
+
 		if !m.ready {
 			m.viewport = viewport.New(viewportWidth, msg.Height-10)
 			m.ready = true
@@ -95,14 +117,105 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case fileLoadedMsg:
 		m.loadingFile = false
+		if m.searchMode || m.historyMode {
+			return m, nil
+		}
 		m.showingImage = false
 		wrapped := wordwrap.String(msg.content, m.viewport.Width)
 		m.viewport.SetContent(wrapped)
 		m.viewport.GotoTop()
 
+	case vaultChangedMsg:
+		return m.handleVaultChange(msg)
+
+	case passphraseNeededMsg:
+		m.loadingFile = false
+		if m.searchMode || m.historyMode {
+			return m, nil
+		}
+		m.showingImage = false
+		m.viewport.SetContent(lipgloss.NewStyle().Foreground(m.theme.Muted).Render("Encrypted note. Press enter to unlock it."))
+		m.viewport.GotoTop()
+		// Ask once on our own; after that the user unlocks with enter.
+		if m.passDeclined || m.passMode || m.editorMode || m.inputMode || m.deleting ||
+			m.trashMode || m.tagPicking || m.linkPicking || msg.path != m.resolveFilePath(m.selectedFile) {
+			return m, nil
+		}
+		return m.promptPassphrase(passPreview, msg.path)
+
+	case linksLoadedMsg:
+		if msg.title == m.selectedFile {
+			m.linksTitle = msg.title
+			m.linksTo = msg.to
+			m.linksFrom = msg.from
+		}
+		return m, nil
+
+	case searchResultsMsg:
+		if !m.searchMode || msg.query != m.searchInput.Value() {
+			return m, nil
+		}
+		m.searchResults = msg.matches
+		m.searchIdx = 0
+		m.setSearchPreview()
+		return m, nil
+
 	case editorSavedMsg:
-		m.list.SetItems(listFiles(m.sortMode))
-		return m, m.list.NewStatusMessage("Saved!")
+		current := m.editorMode && msg.path == m.editorFile
+		if msg.err != nil {
+			if current {
+				m.editorMsg = "Save failed: " + msg.err.Error()
+				return m, nil
+			}
+			return m, m.list.NewStatusMessage("Save failed: " + msg.err.Error())
+		}
+		m.list.SetItems(m.listItems())
+		if current {
+			m.editorSaved = msg.content
+			m.editorMsg = "Saved!"
+			if msg.auto {
+				m.editorMsg = "Autosaved " + time.Now().Format("15:04:05")
+			}
+		}
+		return m, nil
+
+	case editorPreviewTickMsg:
+		if !m.editorMode || !m.editorPreview || msg.seq != m.previewSeq {
+			return m, nil
+		}
+		return m, m.renderEditorPreview()
+
+	case editorPreviewMsg:
+		if !m.editorMode || !m.editorPreview || msg.seq != m.previewSeq {
+			return m, nil
+		}
+		m.previewPane.SetContent(msg.content)
+		m.syncPreviewScroll()
+		return m, nil
+
+	case editorSyntaxTickMsg:
+		if !m.editorMode || msg.seq != m.syntaxSeq {
+			return m, nil
+		}
+		return m, m.highlightEditor()
+
+	case editorSyntaxMsg:
+		if !m.editorMode || msg.seq != m.syntaxSeq {
+			return m, nil
+		}
+		m.editorSyntax, m.syntaxFor = msg.colors, msg.value
+		return m, nil
+
+	case autosaveTickMsg:
+		if !m.editorMode || msg.session != m.editorSession {
+			return m, nil
+		}
+		if m.editorDirty() {
+			snapshot := !m.editorSnapshotted
+			m.editorSnapshotted = true
+			return m, tea.Batch(saveEditorContent(m.editorFile, m.editorContent.Value(), true, snapshot), m.autosaveTick())
+		}
+		return m, m.autosaveTick()
 
 	case clearViewportMsg:
 		m.viewport.SetContent(strings.Repeat("\n", m.viewport.Height))
@@ -129,7 +242,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case fileEditedMsg:
-		m.list.SetItems(listFiles(m.sortMode))
+		m.list.SetItems(m.listItems())
 		for i, it := range m.list.Items() {
 			if it.(item).title == m.selectedFile {
 				m.list.Select(i)
@@ -146,22 +259,230 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// EDITOR MODE
 		if m.editorMode {
+			return m.updateEditorMode(msg)
+		}
+
+		// PASSPHRASE PROMPT
+		if m.passMode {
 			switch msg.String() {
-			case "ctrl+s":
-				return m, saveEditorContent(m.editorFile, m.editorContent.Value())
-			case "ctrl+q":
-				m.editorMode = false
-				m.editorContent.Blur()
-				m.list.SetItems(listFiles(m.sortMode))
-				if m.showPreview {
+			case "enter":
+				return m.submitPassphrase()
+			case "esc":
+				m.passMode = false
+				m.passFirst = ""
+				m.passInput.SetValue("")
+				m.passInput.Blur()
+				if m.passAction == passPreview {
+					m.passDeclined = true
+				}
+				return m, nil
+			}
+			m.passInput, cmd = m.passInput.Update(msg)
+			return m, cmd
+		}
+
+		// SEARCH MODE
+		if m.searchMode {
+			switch msg.String() {
+			case "esc":
+				m.searchMode = false
+				m.searchInput.Blur()
+				m.searchResults = nil
+				m.viewport.SetContent("")
+				if m.selectedFile != "" && m.showPreview {
 					m.loadingFile = true
 					return m, tea.Batch(m.spinner.Tick, m.loadFileOrImage(m.resolveFilePath(m.selectedFile)))
 				}
 				return m, nil
+
+			case "up", "ctrl+k":
+				if m.searchIdx > 0 {
+					m.searchIdx--
+					m.setSearchPreview()
+				}
+				return m, nil
+
+			case "down", "ctrl+j":
+				if m.searchIdx < len(m.searchResults)-1 {
+					m.searchIdx++
+					m.setSearchPreview()
+				}
+				return m, nil
+
+			case "enter":
+				if len(m.searchResults) == 0 {
+					return m, nil
+				}
+				return m.openSearchMatch(m.searchResults[m.searchIdx])
+			}
+
+			prev := m.searchInput.Value()
+			m.searchInput, cmd = m.searchInput.Update(msg)
+			if m.searchInput.Value() != prev {
+				if m.searchInput.Value() == "" {
+					m.searchResults = nil
+					m.setSearchPreview()
+					return m, cmd
+				}
+				return m, tea.Batch(cmd, runSearch(m.searchInput.Value(), m.sortMode))
+			}
+			return m, cmd
+		}
+
+		// LINK PICKER MODE
+		if m.linkPicking {
+			targets := m.linkTargets()
+			// The links can change under the picker when the vault is refreshed.
+			if len(targets) == 0 {
+				m.linkPicking = false
+				return m, m.list.NewStatusMessage("No linked notes")
+			}
+			m.linkIdx = min(m.linkIdx, len(targets)-1)
+			switch msg.String() {
+			case "up", "ctrl+k":
+				m.linkIdx = max(0, m.linkIdx-1)
+			case "down", "ctrl+j":
+				m.linkIdx = max(0, min(len(targets)-1, m.linkIdx+1))
+			case "enter":
+				m.linkPicking = false
+				if m.linkIdx >= 0 && m.linkIdx < len(targets) {
+					return m.jumpToNote(targets[m.linkIdx])
+				}
+			case "esc":
+				m.linkPicking = false
 			}
-			var editorCmd tea.Cmd
-			m.editorContent, editorCmd = m.editorContent.Update(msg)
-			return m, editorCmd
+			return m, nil
+		}
+
+		// TAG PICKER MODE
+		if m.tagPicking {
+			switch msg.String() {
+			case "up", "ctrl+k":
+				m.tagIdx = max(0, m.tagIdx-1)
+			case "down", "ctrl+j":
+				m.tagIdx = min(len(m.tagOptions)-1, m.tagIdx+1)
+			case " ", "tab":
+				if m.tagIdx < len(m.tagOptions) {
+					name := m.tagOptions[m.tagIdx].name
+					m.tagSelected[name] = !m.tagSelected[name]
+				}
+			case "enter":
+				m.tagPicking = false
+				m.activeTags = nil
+				for _, t := range m.tagOptions {
+					if m.tagSelected[t.name] {
+						m.activeTags = append(m.activeTags, t.name)
+					}
+				}
+				m.list.ResetFilter()
+				m.list.SetItems(m.listItems())
+				m.list.Select(0)
+				m.selectedFile = ""
+				if it, ok := m.list.SelectedItem().(item); ok {
+					m.selectedFile = it.title
+					if m.showPreview {
+						m.loadingFile = true
+						return m, tea.Batch(m.spinner.Tick, m.loadFileOrImage(m.resolveFilePath(it.title)))
+					}
+				}
+				m.viewport.SetContent("")
+				return m, clearKittyGraphics()
+			case "esc":
+				m.tagPicking = false
+			}
+			return m, nil
+		}
+
+		// TRASH MODE
+		if m.trashMode {
+			if m.trashPurging {
+				switch msg.String() {
+				case "y", "Y":
+					m.trashPurging = false
+					if m.trashIdx >= 0 && m.trashIdx < len(m.trashEntries) {
+						e := m.trashEntries[m.trashIdx]
+						purgeTrash(e)
+						if m.lastTrashed != nil && m.lastTrashed.ID == e.ID {
+							m.lastTrashed = nil
+						}
+						m.trashEntries = listTrash()
+						m.trashIdx = min(m.trashIdx, max(0, len(m.trashEntries)-1))
+						return m, m.list.NewStatusMessage("Purged " + e.Path)
+					}
+				case "n", "N", "esc":
+					m.trashPurging = false
+				}
+				return m, nil
+			}
+
+			// The trash key closes the browser it opened, whatever it's bound to.
+			if key.Matches(msg, m.keys.Trash) {
+				m.trashMode = false
+				m.trashEntries = nil
+				return m, nil
+			}
+			switch msg.String() {
+			case "up", "ctrl+k", "k":
+				m.trashIdx = max(0, m.trashIdx-1)
+			case "down", "ctrl+j", "j":
+				m.trashIdx = max(0, min(len(m.trashEntries)-1, m.trashIdx+1))
+			case "enter", "r":
+				if m.trashIdx >= 0 && m.trashIdx < len(m.trashEntries) {
+					e := m.trashEntries[m.trashIdx]
+					if m.lastTrashed != nil && m.lastTrashed.ID == e.ID {
+						m.lastTrashed = nil
+					}
+					newM, restoreCmd := m.restoreTrashed(e)
+					m = newM.(model)
+					m.trashEntries = listTrash()
+					m.trashIdx = min(m.trashIdx, max(0, len(m.trashEntries)-1))
+					return m, restoreCmd
+				}
+			case "d", "x", "delete":
+				if m.trashIdx >= 0 && m.trashIdx < len(m.trashEntries) {
+					m.trashPurging = true
+				}
+			case "esc", "q":
+				m.trashMode = false
+				m.trashEntries = nil
+			}
+			return m, nil
+		}
+
+		// HISTORY MODE
+		if m.historyMode {
+			// Like the trash key, the history key closes what it opened.
+			if key.Matches(msg, m.keys.History) {
+				return m.closeHistory()
+			}
+			switch msg.String() {
+			case "up", "ctrl+k", "k":
+				if m.historyIdx > 0 {
+					m.historyIdx--
+					m.setHistoryPreview()
+				}
+			case "down", "ctrl+j", "j":
+				if m.historyIdx < len(m.historySnaps)-1 {
+					m.historyIdx++
+					m.setHistoryPreview()
+				}
+			case "enter", "r":
+				if m.historyIdx < len(m.historySnaps) {
+					s := m.historySnaps[m.historyIdx]
+					path := m.resolveFilePath(m.historyFile)
+					if err := restoreSnapshot(path, s); err != nil {
+						return m, m.list.NewStatusMessage("Restore failed: " + err.Error())
+					}
+					m.historySnaps = listSnapshots(path)
+					m.historyIdx = 0
+					m.setHistoryPreview()
+					m.list.SetItems(m.listItems())
+					return m, m.list.NewStatusMessage("Restored version from " + s.time.Format(time.RFC822))
+				}
+			case "esc", "q":
+				return m.closeHistory()
+			}
+			return m, nil
 		}
 
 		// DELETE CONFIRMATION MODE
@@ -170,14 +491,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case "y", "Y":
 				if it, ok := m.list.SelectedItem().(item); ok {
 					path := m.resolveFilePath(it.title)
-					os.Remove(path)
-					deleteMetaDesc(path)
-					m.list.SetItems(listFiles(m.sortMode))
 					m.deleting = false
+					entry, err := moveToTrash(path)
+					if err != nil {
+						return m, m.list.NewStatusMessage("Delete failed: " + err.Error())
+					}
+					m.lastTrashed = &entry
+					m.list.SetItems(m.listItems())
 					m.selectedFile = ""
 					m.showingImage = false
 					m.viewport.SetContent("")
-					statusCmd := m.list.NewStatusMessage("Deleted " + it.title)
+					statusCmd := m.list.NewStatusMessage("Moved " + it.title + " to trash (ctrl+z to undo)")
 					return m, tea.Batch(statusCmd, clearKittyGraphics())
 				}
 			case "n", "N", "esc":
@@ -216,27 +540,24 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 					newPath := filepath.Join(vaultDir, name)
 
-					oldDesc := readMetaDesc(oldPath)
-					finalDesc := desc
-					if finalDesc == "" {
-						finalDesc = oldDesc
-					}
-
-					os.MkdirAll(filepath.Dir(newPath), 0o755)
-					os.Rename(oldPath, newPath)
-					deleteMetaDesc(oldPath)
-					writeMetaDesc(newPath, finalDesc)
-
-					rel, _ := filepath.Rel(vaultDir, newPath)
-					m.selectedFile = rel
-
 					m.renameMode = false
 					m.inputMode = false
 					m.inputStep = 0
 					m.input.SetValue("")
 					m.descInput.SetValue("")
 					m.input.Focus()
-					m.list.SetItems(listFiles(m.sortMode))
+
+					if err := renameNote(oldPath, newPath); err != nil {
+						m.list.SetItems(m.listItems())
+						return m, m.list.NewStatusMessage("Rename failed: " + err.Error())
+					}
+					if desc != "" {
+						writeMetaDesc(newPath, desc)
+					}
+
+					rel, _ := filepath.Rel(vaultDir, newPath)
+					m.selectedFile = rel
+					m.list.SetItems(m.listItems())
 					return m, nil
 				}
 
@@ -250,13 +571,24 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 
+				// pick a template, if there are any
+				if m.inputStep == 1 {
+					m.templates = listTemplates()
+					m.templateIdx = 0
+					if len(m.templates) > 0 {
+						m.inputStep = 2
+						m.descInput.Blur()
+						return m, nil
+					}
+				}
+
 				// create the file
 				name := m.input.Value()
 				desc := m.descInput.Value()
 
 				var path string
 				if name == "" {
-					path = filepath.Join(vaultDir, "note.md")
+					path = filepath.Join(vaultDir, time.Now().Format("2006-01-02-150405")+".md")
 				} else {
 					if filepath.Ext(name) == "" {
 						name += ".md"
@@ -264,9 +596,23 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					path = filepath.Join(vaultDir, name)
 				}
 
+				cursorRow, cursorCol := -1, -1
 				os.MkdirAll(filepath.Dir(path), 0o755)
 				if _, err := os.Stat(path); os.IsNotExist(err) {
-					os.WriteFile(path, []byte{}, 0o644)
+					var content string
+					if m.inputStep == 2 && m.templateIdx > 0 {
+						if tmpl, err := readTemplate(m.templates[m.templateIdx-1]); err == nil {
+							content, cursorRow, cursorCol = applyTemplate(tmpl, path, desc, time.Now())
+						}
+					}
+					if isEncryptedFile(path) && content != "" && havePassphrase() {
+						writeEncryptedNote(path, []byte(content))
+					} else if isEncryptedFile(path) {
+						// An empty file is a valid, empty encrypted note; plaintext never hits the disk.
+						os.WriteFile(path, nil, 0o600)
+					} else {
+						os.WriteFile(path, []byte(content), 0o644)
+					}
 				}
 
 				writeMetaDesc(path, desc)
@@ -276,26 +622,37 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 				m.inputMode = false
 				m.inputStep = 0
+				m.templates = nil
+				m.templateIdx = 0
 				m.input.SetValue("")
 				m.descInput.SetValue("")
 				m.input.Focus()
-				m.list.SetItems(listFiles(m.sortMode))
+				m.list.SetItems(m.listItems())
+
+				return m.editNote(path, cursorRow, cursorCol)
+
+			case "up", "ctrl+k":
+				if m.inputStep == 2 {
+					m.templateIdx = max(0, m.templateIdx-1)
+					return m, nil
+				}
 
-				if m.editor == "inbuilt" {
-					var editorCmd tea.Cmd
-					m, editorCmd = openInbuiltEditor(path, m)
-					return m, editorCmd
+			case "down", "ctrl+j":
+				if m.inputStep == 2 {
+					m.templateIdx = min(len(m.templates), m.templateIdx+1)
+					return m, nil
 				}
-				return m, openInEditor(path, m.editor)
 
 			case "esc":
 				m.inputMode = false
 				m.renameMode = false
 				m.inputStep = 0
+				m.templates = nil
+				m.templateIdx = 0
 				m.input.SetValue("")
 				m.descInput.SetValue("")
 				m.input.Focus()
-				m.list.SetItems(listFiles(m.sortMode))
+				m.list.SetItems(m.listItems())
 				return m, nil
 			}
 
@@ -303,7 +660,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.input, cmd = m.input.Update(msg)
 				val := m.input.Value()
 				if val != "" {
-					allItems := listFiles(m.sortMode)
+					allItems := m.listItems()
 					var filtered []list.Item
 					lowerVal := strings.ToLower(val)
 					for _, it := range allItems {
@@ -313,9 +670,9 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 					m.list.SetItems(filtered)
 				} else {
-					m.list.SetItems(listFiles(m.sortMode))
+					m.list.SetItems(m.listItems())
 				}
-			} else {
+			} else if m.inputStep == 1 {
 				m.descInput, cmd = m.descInput.Update(msg)
 			}
 			return m, cmd
@@ -330,14 +687,209 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.input.Focus()
 			return m, nil
 
+		case key.Matches(msg, m.keys.Search):
+			if m.list.FilterState() == list.Filtering {
+				break
+			}
+			m.searchMode = true
+			m.searchInput.SetValue("")
+			m.searchInput.Focus()
+			m.searchResults = nil
+			m.searchIdx = 0
+			m.showingImage = false
+			m.loadingFile = false
+			m.setSearchPreview()
+			return m, tea.Batch(clearKittyGraphics(), textinput.Blink)
+
+		case key.Matches(msg, m.keys.FollowLink):
+			if m.list.FilterState() == list.Filtering {
+				break
+			}
+			if m.linksTitle != m.selectedFile {
+				m.linksTitle = m.selectedFile
+				m.linksTo, m.linksFrom = noteLinks(m.selectedFile)
+			}
+			targets := m.linkTargets()
+			if len(targets) == 0 {
+				return m, m.list.NewStatusMessage("No linked notes")
+			}
+			if len(targets) == 1 {
+				return m.jumpToNote(targets[0])
+			}
+			m.linkPicking = true
+			m.linkIdx = 0
+			return m, nil
+
+		case key.Matches(msg, m.keys.Tags):
+			if m.list.FilterState() == list.Filtering {
+				break
+			}
+			m.tagOptions = vaultTags(listFiles(m.sortMode))
+			if len(m.tagOptions) == 0 {
+				return m, m.list.NewStatusMessage("No tags in this vault")
+			}
+			m.tagSelected = map[string]bool{}
+			for _, t := range m.activeTags {
+				m.tagSelected[t] = true
+			}
+			m.tagIdx = 0
+			m.tagPicking = true
+			return m, nil
+
+		case key.Matches(msg, m.keys.History):
+			if m.list.FilterState() == list.Filtering {
+				break
+			}
+			it, ok := m.list.SelectedItem().(item)
+			if !ok {
+				return m, nil
+			}
+			snaps := listSnapshots(m.resolveFilePath(it.title))
+			if len(snaps) == 0 {
+				return m, m.list.NewStatusMessage("No earlier versions of " + it.title)
+			}
+			m.historyMode = true
+			m.historyFile = it.title
+			m.historySnaps = snaps
+			m.historyIdx = 0
+			m.showingImage = false
+			m.loadingFile = false
+			m.setHistoryPreview()
+			return m, clearKittyGraphics()
+
+		case key.Matches(msg, m.keys.Trash):
+			if m.list.FilterState() == list.Filtering {
+				break
+			}
+			m.trashMode = true
+			m.trashPurging = false
+			m.trashEntries = listTrash()
+			m.trashIdx = 0
+			m.showingImage = false
+			return m, clearKittyGraphics()
+
+		case key.Matches(msg, m.keys.UndoDelete):
+			if m.list.FilterState() == list.Filtering {
+				break
+			}
+			if m.lastTrashed == nil {
+				return m, m.list.NewStatusMessage("Nothing to undo")
+			}
+			e := *m.lastTrashed
+			m.lastTrashed = nil
+			return m.restoreTrashed(e)
+
+		case key.Matches(msg, m.keys.Daily):
+			if m.list.FilterState() == list.Filtering {
+				break
+			}
+			return m.openDailyNote(time.Now())
+
+		case m.list.FilterState() != list.Filtering && (key.Matches(msg, m.keys.PrevDay) || key.Matches(msg, m.keys.NextDay)):
+			dir := 1
+			if key.Matches(msg, m.keys.PrevDay) {
+				dir = -1
+			}
+			day := time.Now()
+			if t, ok := dailyNoteDate(m.selectedFile, m.dailyPath); ok {
+				day = t
+			}
+			title, ok := adjacentDailyNote(day, dir, m.dailyPath)
+			if !ok {
+				if dir < 0 {
+					return m, m.list.NewStatusMessage("No earlier daily note")
+				}
+				return m, m.list.NewStatusMessage("No later daily note")
+			}
+			return m.jumpToNote(title)
+
+		case key.Matches(msg, m.keys.Encrypt):
+			if m.list.FilterState() == list.Filtering {
+				break
+			}
+			it, ok := m.list.SelectedItem().(item)
+			if !ok {
+				return m, nil
+			}
+			path := m.resolveFilePath(it.title)
+			if it.isDir || isImageFile(path) {
+				return m, m.list.NewStatusMessage("Only notes can be encrypted")
+			}
+			return m.toggleEncryption(path)
+
+		case key.Matches(msg, m.keys.Pin):
+			if m.list.FilterState() == list.Filtering {
+				break
+			}
+			it, ok := m.list.SelectedItem().(item)
+			if !ok {
+				return m, nil
+			}
+			if it.isDir {
+				return m, m.list.NewStatusMessage("Folders can't be pinned")
+			}
+			if err := setPinned(m.resolveFilePath(it.title), !it.pinned); err != nil {
+				return m, m.list.NewStatusMessage("Pin failed: " + err.Error())
+			}
+			status := "Pinned " + it.title
+			if it.pinned {
+				status = "Unpinned " + it.title
+			}
+			updated, cmd := m.refreshTree(it.title)
+			m = updated.(model)
+			return m, tea.Batch(cmd, m.list.NewStatusMessage(status))
+
+		case key.Matches(msg, m.keys.PinnedOnly):
+			if m.list.FilterState() == list.Filtering {
+				break
+			}
+			m.pinnedOnly = !m.pinnedOnly
+			m.list.ResetFilter()
+			return m.refreshTree(m.selectedFile)
+
 		case key.Matches(msg, m.keys.Delete):
-			if m.list.SelectedItem() != nil {
+			if it, ok := m.list.SelectedItem().(item); ok {
+				if it.isDir {
+					return m, m.list.NewStatusMessage("Folders can't be deleted from here")
+				}
 				m.deleting = true
 			}
 			return m, nil
 
+		case key.Matches(msg, m.keys.ToggleTree):
+			if m.list.FilterState() == list.Filtering {
+				break
+			}
+			m.treeView = !m.treeView
+			if m.treeView && m.selectedFile != "" {
+				expandParents(m.selectedFile, m.expanded)
+			}
+			return m.refreshTree(m.selectedFile)
+
+		case m.treeView && m.list.FilterState() != list.Filtering && (msg.String() == "right" || msg.String() == "left"):
+			it, ok := m.list.SelectedItem().(item)
+			if !ok {
+				break
+			}
+			if msg.String() == "right" {
+				if it.isDir && !m.expanded[it.title] {
+					m.expanded[it.title] = true
+					return m.refreshTree(it.title)
+				}
+				return m, nil
+			}
+			if it.isDir && m.expanded[it.title] {
+				delete(m.expanded, it.title)
+				return m.refreshTree(it.title)
+			}
+			if parent := filepath.Dir(it.title); parent != "." {
+				delete(m.expanded, parent)
+				return m.refreshTree(parent)
+			}
+			return m, nil
+
 		case key.Matches(msg, m.keys.Rename):
-			if it, ok := m.list.SelectedItem().(item); ok {
+			if it, ok := m.list.SelectedItem().(item); ok && !it.isDir {
 				m.renameMode = true
 				m.renameTarget = it.title
 				m.inputMode = true
@@ -351,7 +903,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case key.Matches(msg, m.keys.CycleSort):
 			m.sortMode = (m.sortMode + 1) % 6
-			m.list.SetItems(listFiles(m.sortMode))
+			m.list.SetItems(m.listItems())
 			m.selectedFile = ""
 			if m.list.SelectedItem() != nil && m.showPreview {
 				i := m.list.SelectedItem().(item)
@@ -391,16 +943,15 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				break
 			}
 			if it, ok := m.list.SelectedItem().(item); ok {
+				if it.isDir {
+					m.expanded[it.title] = !m.expanded[it.title]
+					return m.refreshTree(it.title)
+				}
 				path := m.resolveFilePath(it.title)
 				if isImageFile(path) {
 					return m, openImageViewer(path)
 				}
-				if m.editor == "inbuilt" {
-					var editorCmd tea.Cmd
-					m, editorCmd = openInbuiltEditor(path, m)
-					return m, editorCmd
-				}
-				return m, openInEditor(path, m.editor)
+				return m.editNote(path, -1, -1)
 			}
 		}
 	}
@@ -424,3 +975,105 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	return m, tea.Batch(cmd, cmdList, cmdViewport, cmdRead)
 }
+
+// setSearchPreview shows the context of the selected search match in the preview viewport.
+func (m *model) setSearchPreview() {
+	if len(m.searchResults) == 0 {
+		m.viewport.SetContent("")
+		return
+	}
+	m.viewport.SetContent(m.searchContext(m.searchResults[m.searchIdx]))
+	m.viewport.GotoTop()
+}
+
+// openSearchMatch leaves search mode and opens the matched file at the matching line.
+func (m model) openSearchMatch(match searchMatch) (tea.Model, tea.Cmd) {
+	m.searchMode = false
+	m.searchInput.Blur()
+	m.searchResults = nil
+	m.selectedFile = match.title
+	for i, it := range m.list.Items() {
+		if it.(item).title == match.title {
+			m.list.Select(i)
+			break
+		}
+	}
+
+	return m.editNote(m.resolveFilePath(match.title), match.line-1, match.col)
+}
+
+// jumpToNote moves the list selection to title, clearing any active filter, and previews it.
+func (m model) jumpToNote(title string) (tea.Model, tea.Cmd) {
+	if m.list.FilterState() != list.Unfiltered {
+		m.list.ResetFilter()
+	}
+	if m.treeView {
+		expandParents(title, m.expanded)
+		m.list.SetItems(m.listItems())
+	}
+	for i, it := range m.list.Items() {
+		if it.(item).title == title {
+			m.list.Select(i)
+			break
+		}
+	}
+	m.selectedFile = title
+	if m.showPreview {
+		m.loadingFile = true
+		return m, tea.Batch(m.spinner.Tick, m.loadFileOrImage(m.resolveFilePath(title)))
+	}
+	return m, loadLinks(title)
+}
+
+// restoreTrashed brings a note back from the trash and selects it in the list.
+func (m model) restoreTrashed(e trashEntry) (tea.Model, tea.Cmd) {
+	rel, err := restoreFromTrash(e)
+	if err != nil {
+		return m, m.list.NewStatusMessage("Restore failed: " + err.Error())
+	}
+	m.list.SetItems(m.listItems())
+	newM, cmd := m.jumpToNote(rel)
+	m = newM.(model)
+	return m, tea.Batch(cmd, m.list.NewStatusMessage("Restored "+rel))
+}
+
+// closeHistory leaves the history browser and puts the selected note back in the preview.
+func (m model) closeHistory() (tea.Model, tea.Cmd) {
+	m.historyMode = false
+	m.historySnaps = nil
+	m.viewport.SetContent("")
+	if m.selectedFile != "" && m.showPreview {
+		m.loadingFile = true
+		return m, tea.Batch(m.spinner.Tick, m.loadFileOrImage(m.resolveFilePath(m.selectedFile)))
+	}
+	return m, nil
+}
+
+// setHistoryPreview shows the diff between the selected version and the current file.
+func (m *model) setHistoryPreview() {
+	if m.historyIdx >= len(m.historySnaps) {
+		m.viewport.SetContent("")
+		return
+	}
+	m.viewport.SetContent(m.historyDiff(m.historySnaps[m.historyIdx]))
+	m.viewport.GotoTop()
+}
+
+// refreshTree rebuilds the list after the tree layout changed, keeping title selected.
+func (m model) refreshTree(title string) (tea.Model, tea.Cmd) {
+	m.list.SetItems(m.listItems())
+	for i, it := range m.list.Items() {
+		if it.(item).title == title {
+			m.list.Select(i)
+			break
+		}
+	}
+	if it, ok := m.list.SelectedItem().(item); ok && it.title != m.selectedFile {
+		m.selectedFile = it.title
+		if m.showPreview {
+			m.loadingFile = true
+			return m, tea.Batch(m.spinner.Tick, m.loadFileOrImage(m.resolveFilePath(it.title)))
+		}
+	}
+	return m, nil
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"strings"
+	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -81,10 +85,42 @@ func (m model) listItemStyles() list.DefaultItemStyles {
 	return s
 }
 
+func (m model) templatePickerView() string {
+	return m.pickerView(append([]string{"(blank)"}, m.templates...), m.templateIdx)
+}
+
+// pickerView renders a small vertical menu with the option at idx highlighted.
+func (m model) pickerView(options []string, idx int) string {
+	normal := lipgloss.NewStyle().Foreground(m.theme.SubText).PaddingLeft(3)
+	selected := lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true).PaddingLeft(1)
+
+	const maxRows = 10
+	top := max(0, min(idx-maxRows/2, len(options)-maxRows))
+	options = options[top:min(len(options), top+maxRows)]
+	idx -= top
+
+	var b strings.Builder
+	for i, name := range options {
+		if i == idx {
+			b.WriteString(selected.Render("> " + name))
+		} else {
+			b.WriteString(normal.Render(name))
+		}
+		if i < len(options)-1 {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
 func (m model) View() string {
 	delegate := list.NewDefaultDelegate()
 	delegate.Styles = m.listItemStyles()
-	m.list.SetDelegate(delegate)
+	if m.treeView {
+		m.list.SetDelegate(treeDelegate{DefaultDelegate: delegate, expanded: m.expanded})
+	} else {
+		m.list.SetDelegate(delegate)
+	}
 	m.list.Styles.Title = m.listTitleStyle()
 	m.list.Styles.FilterPrompt = lipgloss.NewStyle().Foreground(m.theme.Primary)
 	m.list.Styles.FilterCursor = lipgloss.NewStyle().Foreground(m.theme.Accent)
@@ -96,8 +132,15 @@ func (m model) View() string {
 	title := m.titleStyle().Render("YapPad")
 	sortStatus := m.statusStyle().Render(fmt.Sprintf("Sort: %s", m.sortMode))
 	header := lipgloss.JoinHorizontal(lipgloss.Center, title, sortStatus)
+	if len(m.activeTags) > 0 {
+		tagStatus := m.statusStyle().Render("Tags: " + formatTags(m.activeTags))
+		header = lipgloss.JoinHorizontal(lipgloss.Center, header, tagStatus)
+	}
+	if m.pinnedOnly {
+		header = lipgloss.JoinHorizontal(lipgloss.Center, header, m.statusStyle().Render("Pinned only"))
+	}
 
-	deletePrompt := lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true).Render("  Are you sure you want to delete this file?") +
+	deletePrompt := lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true).Render("  Move this file to the trash?") +
 		lipgloss.NewStyle().Foreground(m.theme.Secondary).Render(" (y/n)")
 
 	if m.deleting {
@@ -106,7 +149,7 @@ func (m model) View() string {
 			if m.showingImage {
 				previewView = m.viewport.View()
 			} else {
-				previewView = fmt.Sprintf("%s\n%s\n%s", m.previewHeader(), m.viewport.View(), m.previewFooter())
+				previewView = m.textPreview()
 			}
 			return fmt.Sprintf(
 				"\n%s\n\n%s\n\n%s",
@@ -123,15 +166,109 @@ func (m model) View() string {
 		)
 	}
 
+	if m.passMode {
+		passPrompt := fmt.Sprintf("  %s %s", m.passPromptLabel(), m.passInput.View())
+		return fmt.Sprintf("\n%s\n\n%s\n\n%s", header, passPrompt, m.list.View())
+	}
+
 	if m.editorMode {
-		editorStatus := m.statusStyle().Render("ctrl+s: save  ctrl+q: close")
+		// Actions unbound in [keys] get no hint.
+		var hints []string
+		for _, b := range []key.Binding{m.keys.EditorSave, m.keys.EditorClose, m.keys.EditorFind} {
+			if b.Enabled() {
+				hints = append(hints, b.Help().Key+": "+b.Help().Desc)
+			}
+		}
+		status := strings.Join(hints, "  ")
+		if m.editorDirty() {
+			status = "● modified  " + status
+		}
+		if m.vimEnabled {
+			status = m.vimStatus() + "  " + status
+		}
+		if m.editorQuitting {
+			status = "Unsaved changes: (s)ave  (d)iscard  (c)ancel"
+		}
+		editorStatus := m.statusStyle().Render(status)
+		if m.editorMsg != "" {
+			editorStatus = lipgloss.JoinHorizontal(lipgloss.Center, editorStatus,
+				lipgloss.NewStyle().Foreground(m.theme.Accent).MarginLeft(2).Render(m.editorMsg))
+		}
+		body := m.editorView()
+		if m.editorPreview {
+			body = lipgloss.JoinHorizontal(lipgloss.Top,
+				lipgloss.NewStyle().Width(m.editorWidth()).Render(body), " ", m.editorPreviewView())
+		}
+		if m.findMode {
+			body = m.findBarView() + "\n" + body
+		}
 		return fmt.Sprintf(
 			"\n%s\n\n%s",
 			lipgloss.JoinHorizontal(lipgloss.Center, title, editorStatus),
-			m.editorContent.View(),
+			body,
 		)
 	}
 
+	if m.historyMode {
+		return fmt.Sprintf("\n%s\n\n%s", header, m.historyView())
+	}
+
+	if m.trashMode {
+		return fmt.Sprintf("\n%s\n\n%s", header, m.trashView())
+	}
+
+	if m.tagPicking {
+		options := make([]string, len(m.tagOptions))
+		for i, t := range m.tagOptions {
+			mark := "[ ]"
+			if m.tagSelected[t.name] {
+				mark = "[x]"
+			}
+			options[i] = fmt.Sprintf("%s #%s (%d)", mark, t.name, t.count)
+		}
+		return fmt.Sprintf(
+			"\n%s\n\n  Filter by tags (space: toggle, enter: apply)\n%s\n\n%s",
+			header,
+			m.pickerView(options, m.tagIdx),
+			m.list.View(),
+		)
+	}
+
+	if m.linkPicking {
+		return fmt.Sprintf(
+			"\n%s\n\n  Go to linked note\n%s\n\n%s",
+			header,
+			m.pickerView(m.linkTargets(), m.linkIdx),
+			m.list.View(),
+		)
+	}
+
+	if m.searchMode {
+		searchBar := fmt.Sprintf("  Search %s", m.searchInput.View())
+		listWidth := m.width / 2
+		if !m.showPreview {
+			listWidth = m.width - 2
+		}
+		results := m.searchResultsView(listWidth, m.height-10)
+		if m.showPreview && len(m.searchResults) > 0 {
+			r := m.searchResults[m.searchIdx]
+			// make room for the search bar
+			m.viewport.Height = max(1, m.viewport.Height-2)
+			previewView := fmt.Sprintf("%s\n%s\n%s",
+				m.previewHeaderFor(fmt.Sprintf("%s:%d", r.title, r.line)),
+				m.viewport.View(),
+				m.previewFooter(),
+			)
+			return fmt.Sprintf(
+				"\n%s\n\n%s\n\n%s",
+				header,
+				searchBar,
+				lipgloss.JoinHorizontal(lipgloss.Top, results, "  ", previewView),
+			)
+		}
+		return fmt.Sprintf("\n%s\n\n%s\n\n%s", header, searchBar, results)
+	}
+
 	if m.inputMode {
 		if m.inputStep == 0 {
 			return fmt.Sprintf(
@@ -141,6 +278,16 @@ func (m model) View() string {
 				m.list.View(),
 			)
 		}
+		if m.inputStep == 2 {
+			return fmt.Sprintf(
+				"\n%s\n\n File Name %s\n Description %s\n Template\n%s\n\n%s",
+				header,
+				m.input.View(),
+				m.descInput.View(),
+				m.templatePickerView(),
+				m.list.View(),
+			)
+		}
 		return fmt.Sprintf(
 			"\n%s\n\n File Name %s\n Description %s\n\n%s",
 			header,
@@ -160,7 +307,7 @@ func (m model) View() string {
 		} else if m.showingImage {
 			previewView = m.viewport.View()
 		} else {
-			previewView = fmt.Sprintf("%s\n%s\n%s", m.previewHeader(), m.viewport.View(), m.previewFooter())
+			previewView = m.textPreview()
 		}
 		return fmt.Sprintf(
 			"\n%s\n\n%s",
@@ -175,3 +322,101 @@ func (m model) View() string {
 		m.list.View(),
 	)
 }
+
+func (m model) trashView() string {
+	help := lipgloss.NewStyle().Foreground(m.theme.Muted)
+	if len(m.trashEntries) == 0 {
+		return "  Trash\n\n" + help.Render("  The trash is empty. (esc: back)")
+	}
+
+	prompt := help.Render("  enter/r: restore  d: purge  esc: back")
+	if m.trashPurging {
+		prompt = lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true).Render("  Permanently delete this file?") +
+			lipgloss.NewStyle().Foreground(m.theme.Secondary).Render(" (y/n)")
+	}
+
+	options := make([]string, len(m.trashEntries))
+	for i, e := range m.trashEntries {
+		options[i] = fmt.Sprintf("%s  %s", e.Path, help.Render("deleted "+e.Deleted.Format(time.RFC822)))
+	}
+	return fmt.Sprintf("  Trash (%d)\n%s\n\n%s", len(m.trashEntries), prompt, m.pickerView(options, m.trashIdx))
+}
+
+// historyDiff renders a coloured unified diff from version s to the current file.
+func (m model) historyDiff(s snapshot) string {
+	path := m.resolveFilePath(m.historyFile)
+	old, err := readSnapshot(path, s)
+	if err != nil {
+		return "Error reading version"
+	}
+	current, _ := os.ReadFile(path)
+	if isEncryptedFile(path) {
+		// Snapshots of encrypted notes are ciphertext too.
+		old, err = decryptNote(old)
+		if err == nil {
+			current, err = decryptNote(current)
+		}
+		if errors.Is(err, errNoPassphrase) {
+			return lipgloss.NewStyle().Foreground(m.theme.Muted).Render("Encrypted note. Unlock it to compare versions.")
+		}
+		if err != nil {
+			return "Error reading version: " + err.Error()
+		}
+	}
+
+	diff := unifiedDiff(
+		m.historyFile+" @ "+s.time.Format(time.RFC822),
+		m.historyFile+" (current)",
+		splitLines(string(old)),
+		splitLines(string(current)),
+		3,
+	)
+	if len(diff) == 0 {
+		return lipgloss.NewStyle().Foreground(m.theme.Muted).Render("No differences from the current version")
+	}
+
+	added := lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	removed := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	hunk := lipgloss.NewStyle().Foreground(m.theme.Accent)
+	fileHeader := lipgloss.NewStyle().Foreground(m.theme.Secondary).Bold(true)
+
+	var b strings.Builder
+	for i, line := range diff {
+		switch {
+		case i < 2:
+			b.WriteString(fileHeader.Render(line))
+		case strings.HasPrefix(line, "@@"):
+			b.WriteString(hunk.Render(line))
+		case strings.HasPrefix(line, "+"):
+			b.WriteString(added.Render(line))
+		case strings.HasPrefix(line, "-"):
+			b.WriteString(removed.Render(line))
+		default:
+			b.WriteString(line)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (m model) historyView() string {
+	help := lipgloss.NewStyle().Foreground(m.theme.Muted)
+	options := make([]string, len(m.historySnaps))
+	for i, s := range m.historySnaps {
+		options[i] = s.time.Format("02 Jan 06 15:04:05")
+	}
+	versions := fmt.Sprintf("  History of %s (%d)\n%s\n\n%s",
+		m.historyFile,
+		len(m.historySnaps),
+		help.Render("  enter/r: restore  esc: back"),
+		m.pickerView(options, m.historyIdx),
+	)
+	if !m.showPreview {
+		return versions
+	}
+
+	listWidth := m.width / 2
+	versions = lipgloss.NewStyle().Width(listWidth).Render(versions)
+	previewView := fmt.Sprintf("%s\n%s\n%s", m.previewHeaderFor("diff"), m.viewport.View(), m.previewFooter())
+	return lipgloss.JoinHorizontal(lipgloss.Top, versions, "  ", previewView)
+}
@@ -0,0 +1,729 @@
+// NOTE: Optional vim-style modal editing for the inbuilt editor
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type vimState int
+
+const (
+	vimNormal vimState = iota
+	vimInsert
+	vimVisual
+	vimVisualLine
+	vimCommand
+)
+
+func (s vimState) String() string {
+	switch s {
+	case vimInsert:
+		return "-- INSERT --"
+	case vimVisual:
+		return "-- VISUAL --"
+	case vimVisualLine:
+		return "-- VISUAL LINE --"
+	}
+	return "-- NORMAL --"
+}
+
+// vimCmd is one parsed normal mode command: [count] [operator [count]] motion, or [count] action.
+type vimCmd struct {
+	count       int
+	op          string
+	motionCount int
+	motion      string
+	action      string
+}
+
+type vimParse int
+
+const (
+	vimIncomplete vimParse = iota
+	vimComplete
+	vimInvalid
+)
+
+var vimMotions = map[string]bool{
+	"h": true, "j": true, "k": true, "l": true,
+	"w": true, "b": true, "e": true, "0": true, "$": true, "G": true, "gg": true,
+}
+
+var vimActions = map[string]bool{
+	"i": true, "a": true, "I": true, "A": true, "o": true, "O": true,
+	"x": true, "D": true, "C": true, "p": true, "P": true,
+	"u": true, "ctrl+r": true, ".": true, "v": true, "V": true, ":": true, "esc": true,
+}
+
+// vimArrows lets the arrow keys work as motions in normal and visual mode.
+var vimArrows = map[string]string{"left": "h", "down": "j", "up": "k", "right": "l", "home": "0", "end": "$"}
+
+func isVimOperator(k string) bool {
+	return k == "d" || k == "c" || k == "y"
+}
+
+/*
+	NOTE:
+
+parseVim reads the keys typed so far. It reports vimIncomplete while a
+command is still being typed (a count, an operator waiting for its motion,
+the first g of gg), so keys are buffered until a whole command is there.
+*/
+func parseVim(keys []string, visual bool) (vimCmd, vimParse) {
+	var c vimCmd
+	i := 0
+	readCount := func() int {
+		n := 0
+		for i < len(keys) && len(keys[i]) == 1 && keys[i][0] >= '0' && keys[i][0] <= '9' {
+			if keys[i] == "0" && n == 0 {
+				break
+			}
+			n = n*10 + int(keys[i][0]-'0')
+			i++
+		}
+		return n
+	}
+	readMotion := func() (string, vimParse) {
+		if i == len(keys) {
+			return "", vimIncomplete
+		}
+		k := keys[i]
+		i++
+		if k == "g" {
+			if i == len(keys) {
+				return "", vimIncomplete
+			}
+			if keys[i] == "g" {
+				return "gg", vimComplete
+			}
+			return "", vimInvalid
+		}
+		if vimMotions[k] {
+			return k, vimComplete
+		}
+		return k, vimInvalid
+	}
+
+	c.count = readCount()
+	if i == len(keys) {
+		return c, vimIncomplete
+	}
+
+	if k := keys[i]; isVimOperator(k) || (visual && k == "x") {
+		i++
+		c.op = k
+		if visual {
+			return c, vimComplete
+		}
+		c.motionCount = readCount()
+		if i < len(keys) && keys[i] == k {
+			c.motion = "line"
+			return c, vimComplete
+		}
+		var status vimParse
+		c.motion, status = readMotion()
+		return c, status
+	}
+
+	if k := keys[i]; vimActions[k] {
+		// Visual mode only knows motions, operators and leaving it.
+		if visual && k != "esc" && k != "v" && k != "V" && k != ":" {
+			return c, vimInvalid
+		}
+		c.action = k
+		return c, vimComplete
+	}
+	var status vimParse
+	c.motion, status = readMotion()
+	return c, status
+}
+
+// vimBuffer is the editor text as runes, with the cursor as an offset into it.
+type vimBuffer struct {
+	text []rune
+	pos  int
+}
+
+func (m model) vimBuffer() vimBuffer {
+	text := []rune(m.editorContent.Value())
+	row, col := m.editorCursor()
+	return vimBuffer{text: text, pos: offsetOf(text, row, col)}
+}
+
+// offsetOf converts a line and rune column into an offset into text.
+func offsetOf(text []rune, row, col int) int {
+	off := 0
+	for r := 0; r < row && off < len(text); off++ {
+		if text[off] == '\n' {
+			r++
+		}
+	}
+	return off + col
+}
+
+func (b vimBuffer) lineStart(off int) int {
+	for off > 0 && b.text[off-1] != '\n' {
+		off--
+	}
+	return off
+}
+
+func (b vimBuffer) lineEnd(off int) int {
+	for off < len(b.text) && b.text[off] != '\n' {
+		off++
+	}
+	return off
+}
+
+// rowCol converts an offset back into a line and rune column.
+func (b vimBuffer) rowCol(off int) (int, int) {
+	row := 0
+	for _, r := range b.text[:off] {
+		if r == '\n' {
+			row++
+		}
+	}
+	return row, off - b.lineStart(off)
+}
+
+// clampNormal keeps the cursor on a character, as normal mode can't sit past the end of a line.
+func (b vimBuffer) clampNormal(off int) int {
+	off = max(0, min(off, len(b.text)))
+	if end := b.lineEnd(off); off >= end && end > b.lineStart(off) {
+		return end - 1
+	}
+	return off
+}
+
+func (b vimBuffer) firstNonBlank(off int) int {
+	off = b.lineStart(off)
+	for off < len(b.text) && (b.text[off] == ' ' || b.text[off] == '\t') {
+		off++
+	}
+	return off
+}
+
+// charClass splits text into vim words: runs of keyword characters or of other non-blanks.
+func charClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	}
+	return 2
+}
+
+func (b vimBuffer) class(off int) int {
+	if off < 0 || off >= len(b.text) {
+		return 0
+	}
+	return charClass(b.text[off])
+}
+
+// emptyLine reports whether off is on a line with nothing on it, which vim treats as a word.
+func (b vimBuffer) emptyLine(off int) bool {
+	return off < len(b.text) && b.text[off] == '\n' && (off == 0 || b.text[off-1] == '\n')
+}
+
+func (b vimBuffer) wordForward(off int) int {
+	if c := b.class(off); c != 0 {
+		for off < len(b.text) && b.class(off) == c {
+			off++
+		}
+	} else if off < len(b.text) {
+		off++
+	}
+	for off < len(b.text) && b.class(off) == 0 && !b.emptyLine(off) {
+		off++
+	}
+	return off
+}
+
+func (b vimBuffer) wordEnd(off int) int {
+	off++
+	for off < len(b.text) && b.class(off) == 0 {
+		off++
+	}
+	c := b.class(off)
+	for off+1 < len(b.text) && b.class(off+1) == c {
+		off++
+	}
+	return min(off, max(0, len(b.text)-1))
+}
+
+func (b vimBuffer) wordBackward(off int) int {
+	off--
+	for off > 0 && b.class(off) == 0 && !b.emptyLine(off) {
+		off--
+	}
+	c := b.class(off)
+	for off > 0 && b.class(off-1) == c && c != 0 {
+		off--
+	}
+	return max(0, off)
+}
+
+// vertical moves count lines up or down, keeping the column where the line is long enough.
+func (b vimBuffer) vertical(off, count int) int {
+	row, col := b.rowCol(off)
+	lines := strings.Count(string(b.text), "\n")
+	row = max(0, min(lines, row+count))
+	start := offsetOf(b.text, row, 0)
+	return min(start+col, b.lineEnd(start))
+}
+
+/*
+	NOTE:
+
+motion works out where a motion lands from off. linewise motions (j, k,
+gg, G) make operators act on whole lines; inclusive ones (e, $) take the
+character they land on with them.
+*/
+func (b vimBuffer) motion(off int, motion string, count, line int) (target int, linewise, inclusive bool) {
+	switch motion {
+	case "h":
+		return max(b.lineStart(off), off-count), false, false
+	case "l":
+		return min(b.lineEnd(off), off+count), false, false
+	case "j":
+		return b.vertical(off, count), true, false
+	case "k":
+		return b.vertical(off, -count), true, false
+	case "0":
+		return b.lineStart(off), false, false
+	case "$":
+		for i := 1; i < count; i++ {
+			off = b.lineEnd(off) + 1
+		}
+		return max(b.lineStart(off), b.lineEnd(min(off, len(b.text)))-1), false, true
+	case "w":
+		for i := 0; i < count; i++ {
+			off = b.wordForward(off)
+		}
+		return off, false, false
+	case "e":
+		for i := 0; i < count; i++ {
+			off = b.wordEnd(off)
+		}
+		return off, false, true
+	case "b":
+		for i := 0; i < count; i++ {
+			off = b.wordBackward(off)
+		}
+		return off, false, false
+	case "gg", "G":
+		lines := strings.Count(string(b.text), "\n")
+		row := lines
+		if motion == "gg" {
+			row = 0
+		}
+		if line > 0 {
+			row = min(line-1, lines)
+		}
+		return b.firstNonBlank(offsetOf(b.text, row, 0)), true, false
+	}
+	return off, false, false
+}
+
+// lineRange widens [from, to] to whole lines, including the final newline when there is one.
+func (b vimBuffer) lineRange(from, to int) (int, int) {
+	start, end := b.lineStart(from), b.lineEnd(to)
+	if end < len(b.text) {
+		return start, end + 1
+	}
+	if start > 0 {
+		// The last line has no newline after it, so take the one before it instead.
+		return start - 1, end
+	}
+	return start, end
+}
+
+// setVimBuffer replaces the editor text, recording the change for undo, and places the cursor at off.
+func (m *model) setVimBuffer(text []rune, off int, recordUndo bool) {
+	if recordUndo {
+		m.editorUndo.record(currentEditState(m.editorContent), editOther, true, time.Now())
+	}
+	b := vimBuffer{text: text}
+	m.editorContent.SetValue(string(text))
+	m.moveVimCursor(b, off)
+}
+
+func (m *model) moveVimCursor(b vimBuffer, off int) {
+	row, col := b.rowCol(max(0, min(off, len(b.text))))
+	moveEditorCursor(&m.editorContent, row, col)
+}
+
+// vimKey handles a key in vim mode. It reports false for keys the regular editor should handle.
+func (m model) vimKey(msg tea.KeyMsg) (model, tea.Cmd, bool) {
+	key := msg.String()
+
+	switch m.vimState {
+	case vimInsert:
+		if !m.vimReplaying && m.vimRecording != nil {
+			m.vimRecording = append(m.vimRecording, msg)
+		}
+		if key == "esc" {
+			m.endVimInsert()
+			return m, nil, true
+		}
+		return m, nil, false
+	case vimCommand:
+		newM, cmd := m.vimCommandKey(msg)
+		return newM, cmd, true
+	}
+
+	// Save, find, preview and friends keep working outside insert mode.
+	if key != "ctrl+r" && m.keys.editorAction(msg) {
+		m.vimPending = nil
+		return m, nil, false
+	}
+	if k, ok := vimArrows[key]; ok && len(m.vimPending) == 0 {
+		msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+	}
+
+	m.vimPending = append(m.vimPending, msg)
+	keys := make([]string, len(m.vimPending))
+	for i, k := range m.vimPending {
+		keys[i] = k.String()
+	}
+	c, status := parseVim(keys, m.vimState != vimNormal)
+	switch status {
+	case vimIncomplete:
+		return m, nil, true
+	case vimInvalid:
+		m.vimPending = nil
+		return m, nil, true
+	}
+
+	pending := m.vimPending
+	m.vimPending = nil
+	newM, cmd := m.vimExec(c, pending)
+	return newM, cmd, true
+}
+
+// startVimInsert switches to insert mode. The whole insert is undone in one go, like in vim.
+func (m *model) startVimInsert(recordUndo bool, keys []tea.KeyMsg) {
+	if recordUndo {
+		m.editorUndo.record(currentEditState(m.editorContent), editOther, true, time.Now())
+	}
+	m.vimUndoPushed = recordUndo
+	m.vimInsertStart = m.editorContent.Value()
+	m.vimState = vimInsert
+	if !m.vimReplaying {
+		m.vimRecording = append([]tea.KeyMsg{}, keys...)
+	}
+}
+
+func (m *model) endVimInsert() {
+	if m.vimUndoPushed && m.editorContent.Value() == m.vimInsertStart {
+		m.editorUndo.discardLast()
+	}
+	if !m.vimReplaying && m.vimRecording != nil {
+		m.vimLastChange = m.vimRecording
+	}
+	m.vimRecording = nil
+	m.vimState = vimNormal
+
+	// Leaving insert mode steps back onto the last inserted character.
+	b := m.vimBuffer()
+	if b.pos > b.lineStart(b.pos) {
+		m.moveVimCursor(b, b.pos-1)
+	}
+}
+
+// vimSelection returns the visual selection as a [from, to) range.
+func (m model) vimSelection(b vimBuffer) (int, int) {
+	from, to := min(m.vimAnchor, b.pos), max(m.vimAnchor, b.pos)
+	if m.vimState == vimVisualLine {
+		return b.lineRange(from, to)
+	}
+	return from, min(len(b.text), to+1)
+}
+
+// vimExec runs a parsed command; keys are what was typed for it, kept for "." to repeat.
+func (m model) vimExec(c vimCmd, keys []tea.KeyMsg) (model, tea.Cmd) {
+	b := m.vimBuffer()
+	count := max(1, c.count) * max(1, c.motionCount)
+	before := m.editorContent.Value()
+	visual := m.vimState == vimVisual || m.vimState == vimVisualLine
+
+	switch {
+	case c.op != "" && visual:
+		from, to := m.vimSelection(b)
+		linewise := m.vimState == vimVisualLine
+		m.vimState = vimNormal
+		m = m.vimOperate(b, c.op, from, to, linewise, keys)
+
+	case c.op != "":
+		from, to, linewise := b.pos, b.pos, false
+		if c.motion == "line" {
+			to = b.vertical(b.pos, count-1)
+			linewise = true
+		} else {
+			motion := c.motion
+			// Like vim, cw on a word changes to its end rather than eating the space after it.
+			if c.op == "c" && motion == "w" && b.class(b.pos) != 0 {
+				motion = "e"
+			}
+			target, lw, inclusive := b.motion(b.pos, motion, count, c.lineArg())
+			from, to, linewise = min(b.pos, target), max(b.pos, target), lw
+			if inclusive && !linewise {
+				to = min(len(b.text), to+1)
+			}
+			if !linewise && !inclusive && to > from && b.text[to-1] == '\n' {
+				to--
+			}
+		}
+		if linewise {
+			from, to = b.lineRange(from, to)
+		}
+		m = m.vimOperate(b, c.op, from, to, linewise, keys)
+
+	case c.motion != "":
+		target, _, _ := b.motion(b.pos, c.motion, count, c.lineArg())
+		m.moveVimCursor(b, b.clampNormal(target))
+
+	default:
+		var cmd tea.Cmd
+		m, cmd = m.vimAction(b, c, count, keys)
+		if c.action == "u" || c.action == "ctrl+r" || c.action == "." {
+			return m, cmd
+		}
+	}
+
+	// Remember what changed the text, for "." to repeat. Inserts are remembered when they end.
+	if m.editorContent.Value() != before && !m.vimReplaying && m.vimState != vimInsert {
+		m.vimLastChange = keys
+	}
+	return m, nil
+}
+
+// lineArg is the line number given to gg or G, or 0 when none was typed.
+func (c vimCmd) lineArg() int {
+	if c.count == 0 && c.motionCount == 0 {
+		return 0
+	}
+	return max(1, c.count) * max(1, c.motionCount)
+}
+
+// vimOperate applies d, c or y to text[from:to].
+func (m model) vimOperate(b vimBuffer, op string, from, to int, linewise bool, keys []tea.KeyMsg) model {
+	m.vimRegister = string(b.text[from:to])
+	m.vimRegLines = linewise
+	if linewise && !strings.HasSuffix(m.vimRegister, "\n") {
+		// Deleting the last line took the newline before it; store it the way p expects.
+		m.vimRegister = strings.TrimPrefix(m.vimRegister, "\n") + "\n"
+	}
+
+	switch op {
+	case "y":
+		m.moveVimCursor(b, from)
+		return m
+	case "c":
+		if linewise {
+			// Change keeps an empty line to type into, so leave the newlines alone.
+			if to > from && b.text[to-1] == '\n' {
+				to--
+			} else if from < to && b.text[from] == '\n' {
+				from++
+			}
+		}
+		m.editorUndo.record(currentEditState(m.editorContent), editOther, true, time.Now())
+		text := append(append([]rune{}, b.text[:from]...), b.text[to:]...)
+		m.setVimBuffer(text, from, false)
+		m.startVimInsert(false, keys)
+		return m
+	}
+
+	text := append(append([]rune{}, b.text[:from]...), b.text[to:]...)
+	nb := vimBuffer{text: text}
+	off := from
+	if linewise {
+		off = nb.firstNonBlank(min(from, len(text)))
+		if from == len(text) && from > 0 {
+			off = nb.firstNonBlank(from - 1)
+		}
+	}
+	m.setVimBuffer(text, nb.clampNormal(off), true)
+	return m
+}
+
+func (m model) vimAction(b vimBuffer, c vimCmd, count int, keys []tea.KeyMsg) (model, tea.Cmd) {
+	switch c.action {
+	case "esc":
+		m.vimState = vimNormal
+	case "v", "V":
+		want := vimVisual
+		if c.action == "V" {
+			want = vimVisualLine
+		}
+		if m.vimState == want {
+			m.vimState = vimNormal
+		} else {
+			if m.vimState == vimNormal {
+				m.vimAnchor = b.pos
+			}
+			m.vimState = want
+		}
+	case ":":
+		m.vimState = vimCommand
+		m.vimCmdline = ""
+	case "u":
+		for i := 0; i < count; i++ {
+			m.undoEditor()
+		}
+		nb := m.vimBuffer()
+		m.moveVimCursor(nb, nb.clampNormal(nb.pos))
+	case "ctrl+r":
+		for i := 0; i < count; i++ {
+			m.redoEditor()
+		}
+	case ".":
+		if len(m.vimLastChange) == 0 {
+			return m, nil
+		}
+		last := m.vimLastChange
+		m.vimReplaying = true
+		for i := 0; i < count; i++ {
+			for _, k := range last {
+				m, _ = m.editorKey(k)
+			}
+		}
+		m.vimReplaying = false
+	case "i", "a", "I", "A":
+		off := b.pos
+		switch c.action {
+		case "a":
+			if off < b.lineEnd(off) {
+				off++
+			}
+		case "I":
+			off = b.firstNonBlank(off)
+		case "A":
+			off = b.lineEnd(off)
+		}
+		m.moveVimCursor(b, off)
+		m.startVimInsert(true, keys)
+	case "o", "O":
+		at := b.lineEnd(b.pos)
+		if c.action == "O" {
+			at = b.lineStart(b.pos)
+		}
+		m.editorUndo.record(currentEditState(m.editorContent), editOther, true, time.Now())
+		text := append(append(append([]rune{}, b.text[:at]...), '\n'), b.text[at:]...)
+		off := at + 1
+		if c.action == "O" {
+			off = at
+		}
+		m.setVimBuffer(text, off, false)
+		m.startVimInsert(false, keys)
+	case "x":
+		end := min(b.lineEnd(b.pos), b.pos+count)
+		if end == b.pos {
+			return m, nil
+		}
+		m.vimRegister, m.vimRegLines = string(b.text[b.pos:end]), false
+		text := append(append([]rune{}, b.text[:b.pos]...), b.text[end:]...)
+		m.setVimBuffer(text, vimBuffer{text: text}.clampNormal(b.pos), true)
+	case "D":
+		m = m.vimOperate(b, "d", b.pos, b.lineEnd(b.pos), false, keys)
+	case "C":
+		m = m.vimOperate(b, "c", b.pos, b.lineEnd(b.pos), false, keys)
+	case "p", "P":
+		if m.vimRegister == "" {
+			return m, nil
+		}
+		paste := []rune(strings.Repeat(m.vimRegister, count))
+		at := b.pos
+		if m.vimRegLines {
+			at = b.lineStart(b.pos)
+			if c.action == "p" {
+				at = b.lineEnd(b.pos)
+				if at == len(b.text) {
+					// Pasting below the last line: move its newline to the front.
+					paste = append([]rune{'\n'}, paste[:len(paste)-1]...)
+				} else {
+					at++
+				}
+			}
+		} else if c.action == "p" && at < b.lineEnd(at) {
+			at++
+		}
+		text := append(append(append([]rune{}, b.text[:at]...), paste...), b.text[at:]...)
+		off := at + len(paste) - 1
+		if m.vimRegLines {
+			off = vimBuffer{text: text}.firstNonBlank(min(at+1, len(text)))
+			if paste[0] != '\n' {
+				off = vimBuffer{text: text}.firstNonBlank(at)
+			}
+		}
+		m.setVimBuffer(text, vimBuffer{text: text}.clampNormal(off), true)
+	}
+	return m, nil
+}
+
+// vimCommandKey edits and runs the : command line.
+func (m model) vimCommandKey(msg tea.KeyMsg) (model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.vimState = vimNormal
+		return m, nil
+	case tea.KeyBackspace:
+		if m.vimCmdline == "" {
+			m.vimState = vimNormal
+			return m, nil
+		}
+		r := []rune(m.vimCmdline)
+		m.vimCmdline = string(r[:len(r)-1])
+		return m, nil
+	case tea.KeyEnter:
+		m.vimState = vimNormal
+		return m.runVimCommand(strings.TrimSpace(m.vimCmdline))
+	case tea.KeyRunes, tea.KeySpace:
+		m.vimCmdline += string(msg.Runes)
+	}
+	return m, nil
+}
+
+// runVimCommand runs :w, :q, :q!, :wq and :x, and :<number> to jump to a line.
+func (m model) runVimCommand(cmd string) (model, tea.Cmd) {
+	switch cmd {
+	case "":
+		return m, nil
+	case "w":
+		return m, m.saveEditor()
+	case "q":
+		if m.editorDirty() {
+			m.editorMsg = "No write since last change (add ! to override)"
+			return m, nil
+		}
+		return m.closeEditor()
+	case "q!":
+		return m.closeEditor()
+	case "wq", "x":
+		return m.saveAndCloseEditor()
+	}
+	if n, err := strconv.Atoi(cmd); err == nil {
+		b := m.vimBuffer()
+		target, _, _ := b.motion(b.pos, "G", 1, n)
+		m.moveVimCursor(b, target)
+		return m, nil
+	}
+	m.editorMsg = "Not an editor command: " + cmd
+	return m, nil
+}
+
+// vimStatus is the mode shown in the editor's status line.
+func (m model) vimStatus() string {
+	if m.vimState == vimCommand {
+		return ":" + m.vimCmdline
+	}
+	return m.vimState.String()
+}
@@ -0,0 +1,196 @@
+// NOTE: Vault watching, so edits made outside YapPad show up in the list and preview
+
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// watchDebounce groups the burst of events a single save produces into one refresh.
+	watchDebounce = 200 * time.Millisecond
+	// pollInterval is how often the vault is rescanned when inotify isn't available.
+	pollInterval = 2 * time.Second
+)
+
+// vaultChangedMsg lists the vault paths (absolute) that changed since the last one.
+type vaultChangedMsg struct {
+	paths []string
+}
+
+/*
+	NOTE:
+
+watchVault reports changed paths on out, batched by watchDebounce. It uses
+inotify where it can and falls back to polling the vault otherwise. Hidden
+directories are ignored; the metadata index sits at the vault root, so
+description and pin changes still show up in the list.
+*/
+func watchVault(out chan<- []string) {
+	raw := make(chan string, 256)
+	if err := inotifyWatch(vaultDir, raw); err != nil {
+		go pollVault(vaultDir, raw)
+	}
+
+	go func() {
+		pending := map[string]bool{}
+		var timer <-chan time.Time
+		for {
+			select {
+			case p := <-raw:
+				pending[p] = true
+				if timer == nil {
+					timer = time.After(watchDebounce)
+				}
+			case <-timer:
+				paths := make([]string, 0, len(pending))
+				for p := range pending {
+					paths = append(paths, p)
+				}
+				pending = map[string]bool{}
+				timer = nil
+				out <- paths
+			}
+		}
+	}()
+}
+
+// waitForVaultChange delivers the next batch of changes as a vaultChangedMsg.
+func waitForVaultChange(ch <-chan []string) tea.Cmd {
+	return func() tea.Msg {
+		return vaultChangedMsg{paths: <-ch}
+	}
+}
+
+// watchedDir reports whether changes inside dir (absolute) matter to YapPad.
+func watchedDir(root, dir string) bool {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return false
+	}
+	if rel == "." {
+		return true
+	}
+	for d := rel; d != "." && d != string(filepath.Separator); d = filepath.Dir(d) {
+		if filepath.Base(d)[0] == '.' {
+			return false
+		}
+	}
+	return true
+}
+
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// scanVault records the modification time and size of every watched file.
+func scanVault(root string) map[string]fileStamp {
+	stamps := map[string]fileStamp{}
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && !watchedDir(root, path) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			stamps[path] = fileStamp{info.ModTime(), info.Size()}
+		}
+		return nil
+	})
+	return stamps
+}
+
+// pollVault is the fallback watcher: it rescans the vault every pollInterval and reports differences.
+func pollVault(root string, out chan<- string) {
+	prev := scanVault(root)
+	for range time.Tick(pollInterval) {
+		cur := scanVault(root)
+		for path, s := range cur {
+			if old, ok := prev[path]; !ok || old != s {
+				out <- path
+			}
+		}
+		for path := range prev {
+			if _, ok := cur[path]; !ok {
+				out <- path
+			}
+		}
+		prev = cur
+	}
+}
+
+/*
+	NOTE:
+
+handleVaultChange refreshes the list after outside edits. Only the changed
+notes are re-read (the rest come from the note cache), the selection stays
+on the same note, and the preview reloads if that note is one that changed.
+*/
+func (m model) handleVaultChange(msg vaultChangedMsg) (tea.Model, tea.Cmd) {
+	cmds := []tea.Cmd{waitForVaultChange(m.vaultEvents)}
+
+	noteCacheMu.Lock()
+	for _, p := range msg.paths {
+		delete(noteCache, p)
+	}
+	noteCacheMu.Unlock()
+
+	// The new-note prompt narrows the list as you type; it refreshes on its own when done.
+	if m.inputMode {
+		return m, tea.Batch(cmds...)
+	}
+
+	selected := m.selectedFile
+	unfiltered := m.list.FilterState() == list.Unfiltered
+	cmds = append(cmds, m.list.SetItems(m.listItems()))
+	stillThere := false
+	for i, it := range m.list.Items() {
+		if it.(item).title == selected {
+			// While a filter is applied the list re-filters on its own and keeps its cursor.
+			if unfiltered {
+				m.list.Select(i)
+			}
+			stillThere = true
+			break
+		}
+	}
+
+	selectedPath := m.resolveFilePath(selected)
+	reload := !stillThere
+	for _, p := range msg.paths {
+		if p == selectedPath {
+			reload = true
+		}
+	}
+	if !reload || m.editorMode || m.searchMode || m.historyMode {
+		return m, tea.Batch(cmds...)
+	}
+
+	if !stillThere {
+		m.selectedFile = ""
+		if it, ok := m.list.SelectedItem().(item); ok {
+			m.selectedFile = it.title
+		}
+	}
+	if m.selectedFile == "" {
+		m.viewport.SetContent("")
+		return m, tea.Batch(cmds...)
+	}
+	if m.showPreview {
+		m.loadingFile = true
+		cmds = append(cmds, m.spinner.Tick, m.loadFileOrImage(m.resolveFilePath(m.selectedFile)))
+	} else {
+		cmds = append(cmds, loadLinks(m.selectedFile))
+	}
+	return m, tea.Batch(cmds...)
+}
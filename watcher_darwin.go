@@ -0,0 +1,10 @@
+//go:build darwin
+
+package main
+
+import "errors"
+
+// inotifyWatch is Linux only; on macOS the vault is polled instead.
+func inotifyWatch(root string, out chan<- string) error {
+	return errors.New("inotify is not available on this platform")
+}
@@ -0,0 +1,102 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_CLOSE_WRITE |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE_SELF | unix.IN_ONLYDIR
+
+// inotifyWatch watches root and every visible directory below it, sending changed paths on out.
+func inotifyWatch(root string, out chan<- string) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	dirs := map[int]string{}
+	// inotify isn't recursive, so each directory gets its own watch.
+	addTree := func(dir string) {
+		filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			if !watchedDir(root, path) {
+				return filepath.SkipDir
+			}
+			wd, err := unix.InotifyAddWatch(fd, path, inotifyMask)
+			if err == nil {
+				mu.Lock()
+				dirs[wd] = path
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+
+	addTree(root)
+	if len(dirs) == 0 {
+		unix.Close(fd)
+		return os.ErrNotExist
+	}
+
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := unix.Read(fd, buf)
+			if err != nil {
+				if err == unix.EINTR {
+					continue
+				}
+				// Lost the watch, keep going by polling.
+				unix.Close(fd)
+				pollVault(root, out)
+				return
+			}
+
+			for off := 0; off+unix.SizeofInotifyEvent <= n; {
+				ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+				nameBytes := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(ev.Len)]
+				off += unix.SizeofInotifyEvent + int(ev.Len)
+
+				mu.Lock()
+				dir, ok := dirs[int(ev.Wd)]
+				if ev.Mask&unix.IN_IGNORED != 0 {
+					delete(dirs, int(ev.Wd))
+				}
+				mu.Unlock()
+				if !ok {
+					continue
+				}
+
+				path := dir
+				if name := string(trimNul(nameBytes)); name != "" {
+					path = filepath.Join(dir, name)
+				}
+				if ev.Mask&unix.IN_ISDIR != 0 && ev.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+					addTree(path)
+				}
+				out <- path
+			}
+		}
+	}()
+	return nil
+}
+
+// trimNul drops the NUL padding inotify appends to event names.
+func trimNul(b []byte) []byte {
+	for i, c := range b {
+		if c == 0 {
+			return b[:i]
+		}
+	}
+	return b
+}